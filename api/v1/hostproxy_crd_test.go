@@ -0,0 +1,40 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("Hostproxy CRD", func() {
+	It("should be listed with a short name and in the all category", func() {
+		data, err := os.ReadFile(filepath.Join("..", "..", "config", "crd", "bases", "networking.raw1z.fr_hostproxies.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		Expect(yaml.Unmarshal(data, crd)).To(Succeed())
+		Expect(crd.Spec.Names.ShortNames).To(ConsistOf("hp"))
+		Expect(crd.Spec.Names.Categories).To(ConsistOf("all"))
+	})
+})
@@ -17,12 +17,167 @@ limitations under the License.
 package v1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// PausedAnnotation freezes the children of a Hostproxy when set to "true", so that they can be
+// maintained by hand without being reconciled
+const PausedAnnotation = "networking.raw1z.fr/paused"
+
+// DeploymentPausedAnnotation pauses the rollouts of the Deployments of a Hostproxy when set to
+// "true": the changes of the spec are applied to the Deployments, but their pods are only replaced
+// once the annotation is removed
+const DeploymentPausedAnnotation = "networking.raw1z.fr/deployment-paused"
+
+const (
+	// DrainAnnotation is set to "true" on the proxy pods which are going to be removed by a scale down
+	// of a Hostproxy draining its pods
+	DrainAnnotation = "networking.raw1z.fr/drain"
+	// DrainedPodCondition is reported by a proxy pod once its connections are drained
+	DrainedPodCondition corev1.PodConditionType = "networking.raw1z.fr/Drained"
+)
+
+const (
+	// DeviceTunVolumeName is the volume of the TUN device added to the proxy pods requesting it
+	DeviceTunVolumeName = "dev-net-tun"
+	// DeviceTunPath is the path of the TUN device, on the nodes and in the proxy container
+	DeviceTunPath = "/dev/net/tun"
+	// ConfigVolumeName is the volume of the ConfigMap configuring the proxy
+	ConfigVolumeName = "config"
+	// ConfigMountPath is the directory of the proxy container in which the keys of the ConfigMap
+	// configuring the proxy are mounted as files. It is passed to the proxy with CONFIG_PATH.
+	ConfigMountPath = "/etc/hostproxy"
+	// CredentialsVolumeName is the volume of the Secret holding the credentials of the proxy
+	CredentialsVolumeName = "credentials"
+	// CredentialsMountPath is the directory of the proxy container in which the keys of the Secret
+	// holding the credentials of the proxy are mounted as files. It is passed to the proxy with
+	// CREDS_PATH.
+	CredentialsMountPath = "/var/run/secrets/hostproxy"
+)
+
+// Reasons of the conditions reported in the status of a Hostproxy, on which the consumers
+// of the status can rely
+const (
+	// ReasonReconciling is reported while the children of the Hostproxy are being reconciled
+	ReasonReconciling = "Reconciling"
+	// ReasonFinalizing is reported while the Hostproxy is being deleted
+	ReasonFinalizing = "Finalizing"
+	// ReasonPaused is reported while the reconciliation of the Hostproxy is paused
+	ReasonPaused = "Paused"
+	// ReasonMissingPorts is reported when the ports of the Hostproxy are not set
+	ReasonMissingPorts = "MissingPorts"
+	// ReasonPortConflict is reported when no free host port can be allocated
+	ReasonPortConflict = "PortConflict"
+	// ReasonDeploymentCreated is reported once the Deployment of the Hostproxy is reconciled
+	ReasonDeploymentCreated = "DeploymentCreated"
+	// ReasonDaemonSetCreated is reported once the DaemonSet of the Hostproxy is reconciled
+	ReasonDaemonSetCreated = "DaemonSetCreated"
+	// ReasonDeploymentFailed is reported when the Deployment can't be created or updated
+	ReasonDeploymentFailed = "DeploymentFailed"
+	// ReasonDaemonSetFailed is reported when the DaemonSet can't be created or updated
+	ReasonDaemonSetFailed = "DaemonSetFailed"
+	// ReasonServiceFailed is reported when the Service can't be reconciled
+	ReasonServiceFailed = "ServiceFailed"
+	// ReasonServiceAccountFailed is reported when the ServiceAccount can't be reconciled
+	ReasonServiceAccountFailed = "ServiceAccountFailed"
+	// ReasonPodDisruptionBudgetFailed is reported when the PodDisruptionBudget can't be reconciled
+	ReasonPodDisruptionBudgetFailed = "PodDisruptionBudgetFailed"
+	// ReasonNetworkPolicyFailed is reported when the NetworkPolicy can't be reconciled
+	ReasonNetworkPolicyFailed = "NetworkPolicyFailed"
+	// ReasonResizing is reported when the replicas of the Deployment can't be updated
+	ReasonResizing = "Resizing"
+	// ReasonScaled is recorded when the replicas of the Deployment are changed
+	ReasonScaled = "Scaled"
+	// ReasonScaledToZero is reported while the Hostproxy has no replicas
+	ReasonScaledToZero = "ScaledToZero"
+	// ReasonImageUnavailable is reported when the proxy image can't be pulled
+	ReasonImageUnavailable = "ImageUnavailable"
+	// ReasonUnschedulable is reported when a proxy pod can't be scheduled
+	ReasonUnschedulable = "Unschedulable"
+	// ReasonHealthy is reported when the proxy pods are healthy
+	ReasonHealthy = "Healthy"
+	// ReasonPodsNotReady is reported while some proxy pods aren't ready
+	ReasonPodsNotReady = "PodsNotReady"
+	// ReasonPodsReady is reported once all the proxy pods are ready
+	ReasonPodsReady = "PodsReady"
+	// ReasonCrashLooping is reported when the proxy pods restart too often
+	ReasonCrashLooping = "CrashLooping"
+	// ReasonProgressDeadlineExceeded is reported when a rollout of the proxy pods takes longer than its deadline
+	ReasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+	// ReasonCleanupFailed is reported when the state of the proxy can't be cleaned up on a node
+	ReasonCleanupFailed = "CleanupFailed"
+	// ReasonConfigMapNotFound is reported when the ConfigMap configuring the proxy doesn't exist
+	ReasonConfigMapNotFound = "ConfigMapNotFound"
+	// ReasonSecretNotFound is reported when the Secret holding the credentials of the proxy doesn't exist
+	ReasonSecretNotFound = "SecretNotFound"
+	// ReasonForeignPods is reported when the selector of the proxy pods matches pods which aren't
+	// run by the Hostproxy, and which its Service would send the traffic to
+	ReasonForeignPods = "ForeignPods"
+)
+
+// HostproxyMode is the kind of workload running the proxy pods
+// +kubebuilder:validation:Enum=Deployment;DaemonSet
+type HostproxyMode string
+
+const (
+	// DeploymentMode runs the proxy pods with a Deployment, behind a Service
+	DeploymentMode HostproxyMode = "Deployment"
+	// DaemonSetMode runs a proxy pod on every node with a DaemonSet, without a Service
+	DaemonSetMode HostproxyMode = "DaemonSet"
+)
+
+// HealthCheckProtocol is the protocol used to check the readiness of the proxy
+// +kubebuilder:validation:Enum=TCP;GRPC
+type HealthCheckProtocol string
+
+const (
+	// TCPHealthCheck checks that the proxy accepts the connections on the cluster port
+	TCPHealthCheck HealthCheckProtocol = "TCP"
+	// GRPCHealthCheck queries the gRPC health service on the cluster port, for HTTP/2 backends
+	GRPCHealthCheck HealthCheckProtocol = "GRPC"
+)
+
+// PortMapping holds the values with which the port mapping format of a Hostproxy is rendered
+// +kubebuilder:object:generate=false
+type PortMapping struct {
+	ClusterPort int32
+	HostPort    int32
+	// HostAddress is the address of the host, without brackets around IPv6 addresses
+	HostAddress string
+}
+
+// Backend is one of the hosts between which a Hostproxy distributes the traffic
+type Backend struct {
+	// Name of the backend, which suffixes the name of its Deployment
+	Name string `json:"name"`
+
+	// Port of the host of the backend
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	HostPort int32 `json:"hostPort"`
+
+	// Address of the host of the backend. It defaults to the host resolved by the proxy.
+	HostAddress string `json:"hostAddress,omitempty"`
+
+	// Weight of the backend relative to the other ones. Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	Weight *int32 `json:"weight,omitempty"`
+}
+
+// EffectiveWeight returns the weight of the backend, taking its default into account
+func (b Backend) EffectiveWeight() int32 {
+	if b.Weight == nil {
+		return 1
+	}
+	return *b.Weight
+}
+
 // HostproxySpec defines the desired state of Hostproxy
 type HostproxySpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
@@ -34,11 +189,270 @@ type HostproxySpec struct {
 	// +kubebuilder:validation:ExclusiveMaximum=false
 	HostPort int32 `json:"hostPort,omitempty"`
 
+	// Let the controller pick a free host port in the range it is configured with when the host port is 0.
+	// The allocated port is reported in the status.
+	AutoAllocate bool `json:"autoAllocate,omitempty"`
+
 	// Port of the service inside the cluster to which the host port is proxied
 	// +kubebuilder:validation:Minimum=0
 	// +kubebuilder:validation:Maximum=65536
 	// +kubebuilder:validation:ExclusiveMaximum=false
 	ClusterPort int32 `json:"clusterPort,omitempty"`
+
+	// Address of the host to which the traffic is proxied. It defaults to the host resolved by the proxy.
+	// IPv6 literals are accepted with or without brackets, e.g. [2001:db8::1].
+	HostAddress string `json:"hostAddress,omitempty"`
+
+	// Hosts between which the traffic is distributed, for instance for a blue/green deployment of
+	// the proxied host. Each backend runs in a Deployment of its own, to which the replicas of the
+	// Hostproxy are given in proportion of its weight, and the Service balances the traffic across
+	// all of them. The host port and address of the Hostproxy are ignored when backends are set.
+	// +listType=map
+	// +listMapKey=name
+	Backends []Backend `json:"backends,omitempty"`
+
+	// IP family policy of the Service, e.g. PreferDualStack on dual-stack clusters
+	// +kubebuilder:validation:Enum=SingleStack;PreferDualStack;RequireDualStack
+	IPFamilyPolicy *corev1.IPFamilyPolicy `json:"ipFamilyPolicy,omitempty"`
+
+	// Kind of workload running the proxy pods. In DaemonSet mode, a proxy pod binds the host port
+	// on every node, and neither a Service nor a PodDisruptionBudget is created.
+	// +kubebuilder:default=Deployment
+	Mode HostproxyMode `json:"mode,omitempty"`
+
+	// Number of proxy pods. Setting it to zero suspends the proxy without deleting the resource
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=1
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Drain the proxy pods before scaling the Hostproxy down. The pods which are going to be removed
+	// are annotated with networking.raw1z.fr/drain, and the replicas are only reduced once all
+	// of them report the networking.raw1z.fr/Drained condition.
+	DrainOnScaleDown bool `json:"drainOnScaleDown,omitempty"`
+
+	// Create a PodDisruptionBudget keeping at least one proxy pod available when there are several replicas
+	// +kubebuilder:default=true
+	CreatePDB *bool `json:"createPDB,omitempty"`
+
+	// Sources allowed to reach the proxy pods, as CIDRs like 10.0.0.0/8 or as label selectors of
+	// namespaces like team=backend. When set, a NetworkPolicy denies the traffic from any other source.
+	AllowedSources []string `json:"allowedSources,omitempty"`
+
+	// Name of the ServiceAccount used to run the proxy pods.
+	// It defaults to the name of the Hostproxy when the ServiceAccount is created by the controller.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Create a ServiceAccount dedicated to the proxy pods
+	CreateServiceAccount bool `json:"createServiceAccount,omitempty"`
+
+	// Mount the token of the ServiceAccount in the proxy pods. Defaults to false since the proxy
+	// doesn't call the Kubernetes API.
+	AutomountServiceAccountToken *bool `json:"automountServiceAccountToken,omitempty"`
+
+	// Topology spread constraints of the proxy pods. When empty and there are several replicas,
+	// the pods are spread across the zones of the cluster.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// Labels of the nodes on which the proxy pods can run
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Selector of the pods serving the host port in the namespace of the Hostproxy. When set, the
+	// proxy pods are only scheduled on the nodes running one of these pods.
+	TargetPodSelector *metav1.LabelSelector `json:"targetPodSelector,omitempty"`
+
+	// Create the headless Service selecting the proxy pods
+	// +kubebuilder:default=true
+	CreateService *bool `json:"createService,omitempty"`
+
+	// Name of the Service, which defaults to the name of the Hostproxy. It has to be changed when
+	// a Service of the same name already exists in the namespace.
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// Namespace of the Service, which defaults to the namespace of the Hostproxy. A Service in
+	// another namespace can neither be owned by the Hostproxy nor select its proxy pods: the
+	// controller manages its endpoints, and deletes it with the finalizer of the Hostproxy.
+	ServiceNamespace string `json:"serviceNamespace,omitempty"`
+
+	// Suffix appended to the names of the Deployment and of the Service, such as -proxy, to avoid
+	// collisions with the objects named after the Hostproxy which already exist in the namespace
+	// +kubebuilder:validation:Pattern=`^[-a-z0-9]*[a-z0-9]$`
+	// +kubebuilder:validation:MaxLength=20
+	NameSuffix string `json:"nameSuffix,omitempty"`
+
+	// Type of the Service. A ClusterIP Service is headless, while a NodePort or a LoadBalancer
+	// one exposes the proxy outside of the cluster.
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +kubebuilder:default=ClusterIP
+	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
+
+	// External traffic policy of a NodePort or a LoadBalancer Service. Local preserves the source IPs
+	// of the clients. It is ignored for the other types of Service.
+	// +kubebuilder:validation:Enum=Cluster;Local
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicy `json:"externalTrafficPolicy,omitempty"`
+
+	// Publish the addresses of the proxy pods in the DNS before they are ready. By default only
+	// the ready proxy pods are resolved by the names of the Service.
+	PublishNotReadyAddresses bool `json:"publishNotReadyAddresses,omitempty"`
+
+	// Liveness probe of the proxy container
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+
+	// Number of restarts of the containers of the proxy pods above which the Hostproxy is reported
+	// as degraded. Defaults to 5.
+	// +kubebuilder:validation:Minimum=0
+	RestartThreshold *int32 `json:"restartThreshold,omitempty"`
+
+	// Restart policy of the proxy pods. The Deployments and the DaemonSets only support Always,
+	// which is the default: a failed proxy is restarted in place rather than being given up.
+	RestartPolicy corev1.RestartPolicy `json:"restartPolicy,omitempty"`
+
+	// Policy of the termination message of the proxy container. FallbackToLogsOnError reports the
+	// last lines of the logs of a proxy which failed without writing a termination message.
+	// +kubebuilder:validation:Enum=File;FallbackToLogsOnError
+	TerminationMessagePolicy corev1.TerminationMessagePolicy `json:"terminationMessagePolicy,omitempty"`
+
+	// Startup probe of the proxy container, for images which take a while to initialize.
+	// When omitted, a conservative one is derived from the liveness probe.
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
+	// Protocol of the readiness probe checking the cluster port. A gRPC health check is more
+	// accurate than a TCP one when the proxied backend speaks HTTP/2.
+	// +kubebuilder:default=TCP
+	HealthCheckProtocol HealthCheckProtocol `json:"healthCheckProtocol,omitempty"`
+
+	// Readiness gates of the proxy pods, so that an external controller or the proxy itself
+	// can report when the host port is actually reachable
+	ReadinessGates []corev1.PodReadinessGate `json:"readinessGates,omitempty"`
+
+	// Strategy used to replace the proxy pods on update in Deployment mode. Use the Recreate strategy
+	// to prevent an old and a new proxy pod from contending for the same host port during a rollout.
+	UpdateStrategy *appsv1.DeploymentStrategy `json:"updateStrategy,omitempty"`
+
+	// Maximum number of proxy pods created above the desired replicas during a rolling update.
+	// Set it to 0 so that an old and a new proxy pod never bind the same host port at once.
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+
+	// Maximum number of proxy pods which can be unavailable during a rolling update
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// Minimum number of seconds a new proxy pod must be ready before being considered available,
+	// so that a rollout doesn't move on before the proxy actually forwards the traffic
+	// +kubebuilder:validation:Minimum=0
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty"`
+
+	// Maximum number of seconds a rollout of the proxy pods can take before the Hostproxy is
+	// reported as degraded. Defaults to the 600 seconds of the Deployments.
+	// +kubebuilder:validation:Minimum=1
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+
+	// Run the proxy pod in the network namespace of the host, so that the host port is bound directly.
+	// The DNS policy of the pod is then set to ClusterFirstWithHostNet to keep resolving cluster names.
+	// Note that the headless Service resolves to the IP of the node running the proxy in this mode.
+	HostNetwork bool `json:"hostNetwork,omitempty"`
+
+	// DNS policy of the proxy pods, for instance None to only use the nameservers of the DNS config.
+	// Defaults to ClusterFirst, or to ClusterFirstWithHostNet when the proxy runs in the network of the host.
+	// +kubebuilder:validation:Enum=ClusterFirstWithHostNet;ClusterFirst;Default;None
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// DNS parameters of the proxy pods, such as custom nameservers resolving the host to proxy
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// Declare the host port on the proxy container, so that it is reserved by Kubernetes on the node
+	// instead of being bound by the proxy process itself
+	UseContainerHostPort bool `json:"useContainerHostPort,omitempty"`
+
+	// Run the proxy at the IP level with raw sockets, for instance to forward ICMP, instead of
+	// forwarding TCP connections. The Service of the proxy then exposes no port and the pods
+	// are not probed on the cluster port.
+	RawMode bool `json:"rawMode,omitempty"`
+
+	// Name of the environment variable passing the port mapping to the proxy. It defaults to PORTS.
+	EnvVarName string `json:"envVarName,omitempty"`
+
+	// Go template of the port mapping passed to the proxy, for images expecting another format,
+	// e.g. {{.ClusterPort}}->{{.HostPort}}. The ClusterPort, HostPort and HostAddress fields are
+	// available. It defaults to clusterPort:hostPort, or clusterPort:hostAddress:hostPort when the
+	// address of the host is set.
+	EnvVarFormat string `json:"envVarFormat,omitempty"`
+
+	// Environment variables passed to the proxy container. The variables managed by the controller,
+	// like the port mapping, can't be overridden and are ignored.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Additional volumes of the proxy pods, for instance a ConfigMap holding the configuration
+	// of the proxy or an emptyDir to store its state
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// Mounts of the volumes into the proxy container
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// Mount the TUN device of the node into the proxy container, for the proxies creating
+	// tunnel interfaces
+	DeviceTun bool `json:"deviceTun,omitempty"`
+
+	// ConfigMap of the namespace holding the configuration files of the proxy, which are mounted
+	// in the directory passed to the proxy with the CONFIG_PATH environment variable. The proxy
+	// pods are rolled out when the ConfigMap is updated.
+	ConfigMapRef *corev1.LocalObjectReference `json:"configMapRef,omitempty"`
+
+	// Secret of the namespace holding the credentials of the proxy, which are mounted in the
+	// directory passed to the proxy with the CREDS_PATH environment variable. The proxy pods are
+	// rolled out when the Secret is updated.
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+
+	// Verbosity of the proxy, passed to the container with the LOG_LEVEL environment variable
+	// +kubebuilder:validation:Enum=debug;info;warn;error
+	// +kubebuilder:default=info
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// Seccomp profile of the proxy pods, overriding the RuntimeDefault profile, for instance to use
+	// a Localhost profile allowing the network operations of the proxy. The Unconfined profile lifts
+	// all the restrictions, which is meant for debugging the raw socket operations.
+	SeccompProfile *corev1.SeccompProfile `json:"seccompProfile,omitempty"`
+
+	// AppArmor profile of the proxy container, like runtime/default or localhost/<profile>
+	AppArmorProfile string `json:"appArmorProfile,omitempty"`
+
+	// Linux capabilities added to the proxy container. Defaults to NET_ADMIN and NET_RAW, which
+	// the proxy needs to set up the forwarding.
+	Capabilities []corev1.Capability `json:"capabilities,omitempty"`
+
+	// Linux capabilities dropped from the proxy container, like ALL to only keep the added ones
+	DropCapabilities []corev1.Capability `json:"dropCapabilities,omitempty"`
+
+	// UID running the proxy pods. The proxy needs the NET_ADMIN capability to set up the forwarding,
+	// so it should only be set for images dropping their privileges after binding the ports.
+	RunAsUser *int64 `json:"runAsUser,omitempty"`
+
+	// Require the proxy pods to run as a non-root user
+	RunAsNonRoot *bool `json:"runAsNonRoot,omitempty"`
+
+	// Group owning the volumes mounted in the proxy pods
+	FSGroup *int64 `json:"fsGroup,omitempty"`
+
+	// Remove the state written by the proxy under /var/lib/hostproxy/<namespace>/<name> on the nodes
+	// when the Hostproxy is deleted, with a Job run on every node which has run a proxy pod
+	CleanupHostState bool `json:"cleanupHostState,omitempty"`
+
+	// Maximum duration in seconds of the cleanup Job of a node, after which the deletion of the
+	// Hostproxy proceeds without waiting for it. Defaults to 300 seconds.
+	// +kubebuilder:validation:Minimum=1
+	CleanupDeadlineSeconds *int64 `json:"cleanupDeadlineSeconds,omitempty"`
+
+	// Port on which the proxy exposes its own metrics. When set, the proxy pods are annotated
+	// to be scraped by Prometheus.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	MetricsPort int32 `json:"metricsPort,omitempty"`
+
+	// Name of the proxy container, which may have to be changed when it collides with the
+	// naming conventions of the sidecars injected into the pods
+	// +kubebuilder:default=hostproxy
+	ContainerName string `json:"containerName,omitempty"`
+
+	// Containers run next to the proxy in its pods, for instance to ship its logs or its metrics
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
 }
 
 // HostproxyStatus defines the observed state of Hostproxy
@@ -53,10 +467,70 @@ type HostproxyStatus struct {
 	// For further information see: https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#typical-status-properties
 
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// The generation of the Hostproxy spec which was last reconciled successfully
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Child resources managed by the Hostproxy, listed as kind/name
+	OwnedResources []string `json:"ownedResources,omitempty"`
+
+	// Image of the proxy run by the Hostproxy
+	Image string `json:"image,omitempty"`
+
+	// Version of the proxy image, as parsed from the image tag
+	ImageVersion string `json:"imageVersion,omitempty"`
+
+	// Host port picked by the controller when the Hostproxy requests an automatic allocation
+	AllocatedHostPort int32 `json:"allocatedHostPort,omitempty"`
+
+	// Name of the Service exposing the proxy in the cluster
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// Port allocated on the nodes when the Service is of the NodePort or LoadBalancer type
+	NodePort int32 `json:"nodePort,omitempty"`
+
+	// Total number of restarts of the containers of the proxy pods
+	RestartCount int32 `json:"restartCount,omitempty"`
+
+	// Version of the operator which last reconciled the Hostproxy, to find the resources managed
+	// by an outdated controller after a partial upgrade
+	ReconciledBy string `json:"reconciledBy,omitempty"`
+
+	// Port mapping passed to the proxy with the environment variable named by envVarName. The
+	// mappings passed to the proxies of several backends are joined with commas.
+	PortsEnv string `json:"portsEnv,omitempty"`
+
+	// Checksum of the configuration mounted in the proxy pods, which are rolled out when it changes
+	ConfigChecksum string `json:"configChecksum,omitempty"`
+
+	// Checksum of the credentials mounted in the proxy pods, which are rolled out when it changes
+	CredentialsChecksum string `json:"credentialsChecksum,omitempty"`
+
+	// Last significant events of the reconciliation, from the oldest to the newest, which outlive
+	// the Kubernetes events for troubleshooting
+	// +kubebuilder:validation:MaxItems=10
+	RecentEvents []EventEntry `json:"recentEvents,omitempty"`
+}
+
+// EventEntry is a significant event of the reconciliation of a Hostproxy, such as the creation,
+// the scaling or the failure of its children
+type EventEntry struct {
+	// Time of the last occurrence of the event
+	Time metav1.Time `json:"time"`
+
+	// Type of the event, Normal or Warning
+	Type string `json:"type"`
+
+	// Reason of the event, in CamelCase
+	Reason string `json:"reason"`
+
+	// Human readable description of the event
+	Message string `json:"message,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=hp,categories=all
 
 // Hostproxy is the Schema for the hostproxies API
 type Hostproxy struct {
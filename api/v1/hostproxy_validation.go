@@ -0,0 +1,297 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"io"
+	"net"
+	pathpkg "path"
+	"strings"
+	"text/template"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// logLevels are the verbosities supported by the proxy
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// Validate checks the spec of a Hostproxy with the rules enforced by the validating webhook,
+// so that a manifest can be validated offline before being applied.
+func Validate(spec HostproxySpec) error {
+	return validateSpec(spec, field.NewPath("spec")).ToAggregate()
+}
+
+// validateSpec returns the errors of the spec of a Hostproxy found at path
+func validateSpec(spec HostproxySpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	for _, port := range []struct {
+		name  string
+		value int32
+	}{{"hostPort", spec.HostPort}, {"clusterPort", spec.ClusterPort}} {
+		// The host port is picked by the controller when it is automatically allocated, and
+		// it is set by each backend when there are several ones
+		if port.name == "hostPort" && port.value == 0 && (spec.AutoAllocate || len(spec.Backends) > 0) {
+			continue
+		}
+		for _, msg := range validation.IsValidPortNum(int(port.value)) {
+			errs = append(errs, field.Invalid(path.Child(port.name), port.value, msg))
+		}
+	}
+
+	if spec.HostAddress != "" {
+		address := strings.TrimSuffix(strings.TrimPrefix(spec.HostAddress, "["), "]")
+		if net.ParseIP(address) == nil && len(validation.IsDNS1123Subdomain(address)) > 0 {
+			errs = append(errs, field.Invalid(path.Child("hostAddress"), spec.HostAddress,
+				"must be an IP address or a DNS name"))
+		}
+	}
+
+	if len(spec.Backends) > 0 {
+		if spec.Mode == DaemonSetMode {
+			errs = append(errs, field.Forbidden(path.Child("backends"), "can't be set in DaemonSet mode"))
+		}
+		var weights int32
+		for i, backend := range spec.Backends {
+			backendPath := path.Child("backends").Index(i)
+			for _, msg := range validation.IsDNS1123Label(backend.Name) {
+				errs = append(errs, field.Invalid(backendPath.Child("name"), backend.Name, msg))
+			}
+			for _, msg := range validation.IsValidPortNum(int(backend.HostPort)) {
+				errs = append(errs, field.Invalid(backendPath.Child("hostPort"), backend.HostPort, msg))
+			}
+			weights += backend.EffectiveWeight()
+		}
+		if weights == 0 {
+			errs = append(errs, field.Invalid(path.Child("backends"), weights,
+				"must have at least one backend with a positive weight"))
+		}
+	}
+
+	if spec.HostNetwork {
+		// In the network namespace of the host, the proxy would forward the traffic to itself
+		if spec.HostPort == spec.ClusterPort && spec.HostAddress == "" {
+			errs = append(errs, field.Invalid(path.Child("clusterPort"), spec.ClusterPort,
+				"must be distinct from the host port when the proxy runs in the network of the host"))
+		}
+		// Kubernetes requires the host port of a container to match its port in this mode
+		if spec.UseContainerHostPort && spec.HostPort != spec.ClusterPort {
+			errs = append(errs, field.Invalid(path.Child("useContainerHostPort"), spec.UseContainerHostPort,
+				"requires the host port to match the cluster port when the proxy runs in the network of the host"))
+		}
+	}
+
+	// Without the nameservers of the cluster, the pods must be given some by the DNS config
+	if spec.DNSPolicy == corev1.DNSNone && (spec.DNSConfig == nil || len(spec.DNSConfig.Nameservers) == 0) {
+		errs = append(errs, field.Required(path.Child("dnsConfig", "nameservers"),
+			"must be set when the DNS policy is None"))
+	}
+
+	// Without any port, a raw mode Service can only be headless
+	if spec.RawMode {
+		if spec.ServiceType != "" && spec.ServiceType != corev1.ServiceTypeClusterIP {
+			errs = append(errs, field.Invalid(path.Child("serviceType"), spec.ServiceType,
+				"must be ClusterIP in raw mode"))
+		}
+		if spec.UseContainerHostPort {
+			errs = append(errs, field.Invalid(path.Child("useContainerHostPort"), spec.UseContainerHostPort,
+				"can't be set in raw mode"))
+		}
+	}
+
+	// Only a Localhost seccomp profile is loaded from a file of the nodes
+	if profile := spec.SeccompProfile; profile != nil {
+		if profile.Type == corev1.SeccompProfileTypeLocalhost && profile.LocalhostProfile == nil {
+			errs = append(errs, field.Required(path.Child("seccompProfile", "localhostProfile"),
+				"must be set when the seccomp profile is Localhost"))
+		} else if profile.Type != corev1.SeccompProfileTypeLocalhost && profile.LocalhostProfile != nil {
+			errs = append(errs, field.Forbidden(path.Child("seccompProfile", "localhostProfile"),
+				"can only be set when the seccomp profile is Localhost"))
+		}
+	}
+
+	// The workloads running the proxy pods only support restarting them
+	if spec.RestartPolicy != "" && spec.RestartPolicy != corev1.RestartPolicyAlways {
+		errs = append(errs, field.NotSupported(path.Child("restartPolicy"), spec.RestartPolicy,
+			[]string{string(corev1.RestartPolicyAlways)}))
+	}
+
+	// The Deployment requires its deadline to leave the pods the time to become available
+	if spec.ProgressDeadlineSeconds != nil && *spec.ProgressDeadlineSeconds <= spec.MinReadySeconds {
+		errs = append(errs, field.Invalid(path.Child("progressDeadlineSeconds"), *spec.ProgressDeadlineSeconds,
+			"must be greater than minReadySeconds"))
+	}
+
+	if spec.Replicas != nil && *spec.Replicas < 0 {
+		errs = append(errs, field.Invalid(path.Child("replicas"), *spec.Replicas, "must be greater than or equal to 0"))
+	}
+
+	if spec.LogLevel != "" {
+		valid := false
+		for _, level := range logLevels {
+			valid = valid || spec.LogLevel == level
+		}
+		if !valid {
+			errs = append(errs, field.NotSupported(path.Child("logLevel"), spec.LogLevel, logLevels))
+		}
+	}
+
+	for i, source := range spec.AllowedSources {
+		if _, _, err := net.ParseCIDR(source); err == nil {
+			continue
+		}
+		if _, err := metav1.ParseToLabelSelector(source); err != nil {
+			errs = append(errs, field.Invalid(path.Child("allowedSources").Index(i), source,
+				"must be a CIDR or a label selector of namespaces"))
+		}
+	}
+
+	if spec.TargetPodSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(spec.TargetPodSelector); err != nil {
+			errs = append(errs, field.Invalid(path.Child("targetPodSelector"), spec.TargetPodSelector, err.Error()))
+		}
+	}
+
+	if spec.ServiceName != "" {
+		for _, msg := range validation.IsDNS1035Label(spec.ServiceName) {
+			errs = append(errs, field.Invalid(path.Child("serviceName"), spec.ServiceName, msg))
+		}
+	}
+	if spec.ServiceNamespace != "" {
+		for _, msg := range validation.IsDNS1123Label(spec.ServiceNamespace) {
+			errs = append(errs, field.Invalid(path.Child("serviceNamespace"), spec.ServiceNamespace, msg))
+		}
+	}
+
+	// The sidecars can't take the name of the proxy container, which defaults to hostproxy
+	containerName := spec.ContainerName
+	if containerName == "" {
+		containerName = "hostproxy"
+	}
+	names := map[string]bool{containerName: true}
+	for i, sidecar := range spec.Sidecars {
+		if names[sidecar.Name] {
+			errs = append(errs, field.Duplicate(path.Child("sidecars").Index(i).Child("name"), sidecar.Name))
+		}
+		names[sidecar.Name] = true
+	}
+
+	if spec.MaxSurge != nil || spec.MaxUnavailable != nil {
+		if spec.UpdateStrategy != nil && spec.UpdateStrategy.Type == appsv1.RecreateDeploymentStrategyType {
+			errs = append(errs, field.Forbidden(path.Child("updateStrategy"),
+				"must be a rolling update when maxSurge or maxUnavailable is set"))
+		}
+		// The Deployment would not be able to make any progress during a rolling update
+		if isZero(spec.MaxSurge) && isZero(spec.MaxUnavailable) {
+			errs = append(errs, field.Invalid(path.Child("maxUnavailable"), spec.MaxUnavailable.String(),
+				"must not be 0 when maxSurge is 0"))
+		}
+	}
+
+	// A capability can't be both added and dropped, except for the ALL capability
+	dropped := map[corev1.Capability]bool{}
+	for _, capability := range spec.DropCapabilities {
+		dropped[capability] = true
+	}
+	for i, capability := range spec.Capabilities {
+		if dropped[capability] && capability != "ALL" {
+			errs = append(errs, field.Invalid(path.Child("capabilities").Index(i), capability,
+				"must not be dropped as well"))
+		}
+	}
+
+	// The volumes of the TUN device, of the configuration and of the credentials are added by the
+	// controller, along with their mounts
+	volumes := map[string]bool{}
+	if spec.DeviceTun {
+		volumes[DeviceTunVolumeName] = true
+	}
+	if spec.ConfigMapRef != nil {
+		volumes[ConfigVolumeName] = true
+		if spec.ConfigMapRef.Name == "" {
+			errs = append(errs, field.Required(path.Child("configMapRef", "name"), "the name of the ConfigMap must be set"))
+		}
+	}
+	if spec.SecretRef != nil {
+		volumes[CredentialsVolumeName] = true
+		if spec.SecretRef.Name == "" {
+			errs = append(errs, field.Required(path.Child("secretRef", "name"), "the name of the Secret must be set"))
+		}
+	}
+	for i, volume := range spec.Volumes {
+		volumePath := path.Child("volumes").Index(i)
+		if volumes[volume.Name] {
+			errs = append(errs, field.Duplicate(volumePath.Child("name"), volume.Name))
+		}
+		volumes[volume.Name] = true
+		if volume.HostPath != nil && !pathpkg.IsAbs(volume.HostPath.Path) {
+			errs = append(errs, field.Invalid(volumePath.Child("hostPath", "path"), volume.HostPath.Path,
+				"must be an absolute path"))
+		}
+	}
+	for i, mount := range spec.VolumeMounts {
+		if (spec.DeviceTun && mount.MountPath == DeviceTunPath) ||
+			(spec.ConfigMapRef != nil && mount.MountPath == ConfigMountPath) ||
+			(spec.SecretRef != nil && mount.MountPath == CredentialsMountPath) {
+			errs = append(errs, field.Duplicate(path.Child("volumeMounts").Index(i).Child("mountPath"), mount.MountPath))
+		}
+	}
+
+	if spec.EnvVarName != "" {
+		for _, msg := range validation.IsEnvVarName(spec.EnvVarName) {
+			errs = append(errs, field.Invalid(path.Child("envVarName"), spec.EnvVarName, msg))
+		}
+		switch spec.EnvVarName {
+		case "LOG_LEVEL", "RAW_MODE", "CONFIG_PATH", "CREDS_PATH":
+			errs = append(errs, field.Invalid(path.Child("envVarName"), spec.EnvVarName,
+				"is already managed by the controller"))
+		}
+	}
+
+	// The format is rendered once, so that the references to unknown fields are caught as well
+	if spec.EnvVarFormat != "" {
+		tmpl, err := template.New("envVarFormat").Parse(spec.EnvVarFormat)
+		if err == nil {
+			err = tmpl.Execute(io.Discard, PortMapping{ClusterPort: spec.ClusterPort, HostPort: spec.HostPort})
+		}
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Child("envVarFormat"), spec.EnvVarFormat, err.Error()))
+		}
+	}
+
+	if spec.ContainerName != "" {
+		for _, msg := range validation.IsDNS1123Label(spec.ContainerName) {
+			errs = append(errs, field.Invalid(path.Child("containerName"), spec.ContainerName, msg))
+		}
+	}
+
+	return errs
+}
+
+// isZero returns whether a maxSurge or maxUnavailable value is zero, either as a number or a percentage
+func isZero(value *intstr.IntOrString) bool {
+	if value == nil {
+		return false
+	}
+	zero, err := intstr.GetScaledValueFromIntOrPercent(value, 100, true)
+	return err == nil && zero == 0
+}
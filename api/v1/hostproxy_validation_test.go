@@ -0,0 +1,145 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("Hostproxy validation", func() {
+	// validateManifest decodes a Hostproxy manifest and validates its spec
+	validateManifest := func(manifest string) error {
+		hostproxy := &Hostproxy{}
+		Expect(yaml.UnmarshalStrict([]byte(manifest), hostproxy)).To(Succeed())
+		return Validate(hostproxy.Spec)
+	}
+
+	It("should accept a valid manifest", func() {
+		Expect(validateManifest(`
+apiVersion: networking.raw1z.fr/v1
+kind: Hostproxy
+metadata:
+  name: backend-db
+spec:
+  hostPort: 5432
+  clusterPort: 5432
+  hostAddress: "[2001:db8::1]"
+  logLevel: debug
+`)).To(Succeed())
+	})
+
+	It("should reject ports out of range", func() {
+		err := validateManifest(`
+apiVersion: networking.raw1z.fr/v1
+kind: Hostproxy
+metadata:
+  name: out-of-range
+spec:
+  clusterPort: 70000
+`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.hostPort"))
+		Expect(err.Error()).To(ContainSubstring("spec.clusterPort"))
+	})
+
+	It("should reject a proxy forwarding to itself in the network of the host", func() {
+		err := validateManifest(`
+apiVersion: networking.raw1z.fr/v1
+kind: Hostproxy
+metadata:
+  name: loop
+spec:
+  hostPort: 8080
+  clusterPort: 8080
+  hostNetwork: true
+`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("must be distinct from the host port"))
+	})
+
+	It("should reject an unsupported log level", func() {
+		err := validateManifest(`
+apiVersion: networking.raw1z.fr/v1
+kind: Hostproxy
+metadata:
+  name: log-level
+spec:
+  hostPort: 5432
+  clusterPort: 5432
+  logLevel: verbose
+`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.logLevel"))
+	})
+
+	It("should reject a restart policy unsupported by the Deployments", func() {
+		err := validateManifest(`
+apiVersion: networking.raw1z.fr/v1
+kind: Hostproxy
+metadata:
+  name: restart-policy
+spec:
+  hostPort: 5432
+  clusterPort: 5432
+  restartPolicy: OnFailure
+`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.restartPolicy"))
+	})
+
+	It("should reject a volume clashing with the TUN device", func() {
+		err := validateManifest(`
+apiVersion: networking.raw1z.fr/v1
+kind: Hostproxy
+metadata:
+  name: device-tun
+spec:
+  hostPort: 5432
+  clusterPort: 5432
+  deviceTun: true
+  volumes:
+  - name: dev-net-tun
+    hostPath:
+      path: dev/net/tun
+`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.volumes[0].name"))
+		Expect(err.Error()).To(ContainSubstring("must be an absolute path"))
+	})
+
+	It("should reject a mount clashing with the configuration of the proxy", func() {
+		err := validateManifest(`
+apiVersion: networking.raw1z.fr/v1
+kind: Hostproxy
+metadata:
+  name: config-map
+spec:
+  hostPort: 5432
+  clusterPort: 5432
+  configMapRef:
+    name: proxy-config
+  volumeMounts:
+  - name: data
+    mountPath: /etc/hostproxy
+`)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("spec.volumeMounts[0].mountPath"))
+	})
+})
@@ -0,0 +1,164 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// ProtectDeleteAnnotation protects a Hostproxy from being deleted by accident when set to "true".
+	ProtectDeleteAnnotation = "networking.raw1z.fr/protect-delete"
+	// ConfirmDeleteAnnotation must be set to "true" to delete a protected Hostproxy.
+	ConfirmDeleteAnnotation = "networking.raw1z.fr/confirm-delete"
+	// WarnPortSwapAnnotation enables the warnings about the ports which look swapped when set to "true".
+	WarnPortSwapAnnotation = "networking.raw1z.fr/warn-port-swap"
+)
+
+// Bounds of the port ranges used to detect the ports which look swapped
+const (
+	// maxWellKnownPort is the last port of the range of the well-known ports
+	maxWellKnownPort = 1023
+	// minEphemeralPort is the first port of the range of the ephemeral ports used by Linux
+	minEphemeralPort = 32768
+)
+
+// log is for logging in this package.
+var hostproxylog = logf.Log.WithName("hostproxy-resource")
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks
+func (r *Hostproxy) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	// The nodes are read from the API server, rather than from a cache of all the nodes
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&hostproxyValidator{nodeReader: mgr.GetAPIReader()}).
+		Complete()
+}
+
+//+kubebuilder:rbac:groups=core,resources=nodes,verbs=list
+//+kubebuilder:webhook:path=/validate-networking-raw1z-fr-v1-hostproxy,mutating=false,failurePolicy=fail,sideEffects=None,groups=networking.raw1z.fr,resources=hostproxies,verbs=create;update;delete,versions=v1,name=vhostproxy.kb.io,admissionReviewVersions=v1
+
+// hostproxyValidator validates the Hostproxies admitted by the webhook
+type hostproxyValidator struct {
+	// nodeReader lists the nodes matched by the node selectors of the Hostproxies. The node
+	// selectors aren't checked without it.
+	nodeReader client.Reader
+}
+
+var _ webhook.CustomValidator = &hostproxyValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *hostproxyValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	r, err := asHostproxy(obj)
+	if err != nil {
+		return nil, err
+	}
+	hostproxylog.Info("validate create", "name", r.Name)
+	return v.warnings(ctx, r), r.validateHostproxy()
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
+// The spec is only validated when it changes and the Hostproxy isn't being deleted, so that a
+// Hostproxy created before a rule was added can still have its finalizer removed.
+func (v *hostproxyValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	r, err := asHostproxy(newObj)
+	if err != nil {
+		return nil, err
+	}
+	hostproxylog.Info("validate update", "name", r.Name)
+
+	old, err := asHostproxy(oldObj)
+	if err != nil {
+		return nil, err
+	}
+	if r.DeletionTimestamp != nil || equality.Semantic.DeepEqual(old.Spec, r.Spec) {
+		return nil, nil
+	}
+	return v.warnings(ctx, r), r.validateHostproxy()
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+// Deleting a Hostproxy drops the connections going through the proxy, so a protected
+// Hostproxy can only be deleted once its deletion has been confirmed.
+func (v *hostproxyValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	r, err := asHostproxy(obj)
+	if err != nil {
+		return nil, err
+	}
+	hostproxylog.Info("validate delete", "name", r.Name)
+
+	if r.Annotations[ProtectDeleteAnnotation] == "true" && r.Annotations[ConfirmDeleteAnnotation] != "true" {
+		return nil, fmt.Errorf("the Hostproxy %s is protected against deletion, set the %s annotation to \"true\" to delete it",
+			r.Name, ConfirmDeleteAnnotation)
+	}
+	return nil, nil
+}
+
+// asHostproxy returns the Hostproxy admitted by the webhook
+func asHostproxy(obj runtime.Object) (*Hostproxy, error) {
+	r, ok := obj.(*Hostproxy)
+	if !ok {
+		return nil, fmt.Errorf("expected a Hostproxy but got a %T", obj)
+	}
+	return r, nil
+}
+
+// validateHostproxy returns an Invalid error listing the problems of the spec, if any
+func (r *Hostproxy) validateHostproxy() error {
+	errs := validateSpec(r.Spec, field.NewPath("spec"))
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Hostproxy").GroupKind(), r.Name, errs)
+}
+
+// warnings returns the warnings about the settings of a Hostproxy which are valid but likely mistaken
+func (v *hostproxyValidator) warnings(ctx context.Context, r *Hostproxy) admission.Warnings {
+	// The clients in the cluster usually reach the proxy on the well-known port of the protocol,
+	// while ephemeral ports are rather found on the host side, so a well-known host port proxied
+	// to an ephemeral cluster port suggests that the ports are swapped
+	var warnings admission.Warnings
+	if r.Annotations[WarnPortSwapAnnotation] == "true" &&
+		r.Spec.HostPort > 0 && r.Spec.HostPort <= maxWellKnownPort && r.Spec.ClusterPort >= minEphemeralPort {
+		warnings = append(warnings, fmt.Sprintf(
+			"spec.hostPort %d and spec.clusterPort %d look swapped: the host port is the port listened on the host, "+
+				"the cluster port is the port exposed in the cluster", r.Spec.HostPort, r.Spec.ClusterPort))
+	}
+
+	// A mistyped node selector would silently leave the proxy pods pending
+	if len(r.Spec.NodeSelector) > 0 && v.nodeReader != nil {
+		nodes := &corev1.NodeList{}
+		if err := v.nodeReader.List(ctx, nodes, client.MatchingLabels(r.Spec.NodeSelector), client.Limit(1)); err != nil {
+			hostproxylog.Error(err, "Failed to list the nodes matched by the node selector", "name", r.Name)
+		} else if len(nodes.Items) == 0 {
+			warnings = append(warnings, "spec.nodeSelector matches no node: the proxy pods will stay pending until a node matches it")
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Hostproxy webhook", func() {
+	// The validator of the webhook reads the nodes from the API server of the test environment
+	validator := &hostproxyValidator{}
+	BeforeEach(func() {
+		validator.nodeReader = k8sClient
+	})
+
+	Context("When deleting a Hostproxy protected against deletion", func() {
+		It("should deny the deletion until it is confirmed", func() {
+			hostproxy := &Hostproxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "protected",
+					Namespace:   "default",
+					Annotations: map[string]string{ProtectDeleteAnnotation: "true"},
+				},
+				Spec: HostproxySpec{HostPort: 10541, ClusterPort: 80},
+			}
+			Expect(k8sClient.Create(ctx, hostproxy)).To(Succeed())
+
+			By("Deleting the Hostproxy without confirmation")
+			err := k8sClient.Delete(ctx, hostproxy)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("protected against deletion"))
+
+			By("Confirming the deletion")
+			hostproxy.Annotations[ConfirmDeleteAnnotation] = "true"
+			Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, hostproxy)).To(Succeed())
+		})
+	})
+
+	Context("When deleting a Hostproxy which isn't protected", func() {
+		It("should allow the deletion", func() {
+			hostproxy := &Hostproxy{
+				ObjectMeta: metav1.ObjectMeta{Name: "unprotected", Namespace: "default"},
+				Spec:       HostproxySpec{HostPort: 10541, ClusterPort: 80},
+			}
+			Expect(k8sClient.Create(ctx, hostproxy)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, hostproxy)).To(Succeed())
+		})
+	})
+
+	Context("When updating a Hostproxy created before its ports were rejected", func() {
+		invalid := func() *Hostproxy {
+			return &Hostproxy{
+				ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "default"},
+				Spec:       HostproxySpec{HostPort: 65536, ClusterPort: 0},
+			}
+		}
+
+		It("should allow the updates of the finalizers", func() {
+			old := invalid()
+			hostproxy := invalid()
+			hostproxy.Finalizers = []string{"networking.raw1z.fr/finalizer"}
+			_, err := validator.ValidateUpdate(ctx, old, hostproxy)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Removing the finalizer")
+			_, err = validator.ValidateUpdate(ctx, hostproxy, old)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should allow the updates while it is being deleted", func() {
+			old := invalid()
+			hostproxy := invalid()
+			now := metav1.Now()
+			hostproxy.DeletionTimestamp = &now
+			hostproxy.Spec.HostPort = 70000
+			_, err := validator.ValidateUpdate(ctx, old, hostproxy)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should reject the updates of its spec", func() {
+			old := invalid()
+			hostproxy := invalid()
+			hostproxy.Spec.ClusterPort = 80
+			_, err := validator.ValidateUpdate(ctx, old, hostproxy)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.hostPort"))
+		})
+	})
+
+	Context("When creating a Hostproxy whose ports look swapped", func() {
+		It("should only warn about the ports when requested", func() {
+			hostproxy := &Hostproxy{
+				ObjectMeta: metav1.ObjectMeta{Name: "swapped", Namespace: "default"},
+				Spec:       HostproxySpec{HostPort: 80, ClusterPort: 40000},
+			}
+			warnings, err := validator.ValidateCreate(ctx, hostproxy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+
+			By("Enabling the warnings")
+			hostproxy.Annotations = map[string]string{WarnPortSwapAnnotation: "true"}
+			warnings, err = validator.ValidateCreate(ctx, hostproxy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("look swapped")))
+
+			By("Using ports in the usual order")
+			hostproxy.Spec = HostproxySpec{HostPort: 40000, ClusterPort: 80}
+			warnings, err = validator.ValidateCreate(ctx, hostproxy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+		})
+	})
+
+	Context("When creating a Hostproxy whose node selector matches no node", func() {
+		It("should warn that the proxy pods will stay pending", func() {
+			hostproxy := &Hostproxy{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-selector", Namespace: "default"},
+				Spec: HostproxySpec{
+					HostPort:     10541,
+					ClusterPort:  80,
+					NodeSelector: map[string]string{"networking.raw1z.fr/proxy": "true"},
+				},
+			}
+			warnings, err := validator.ValidateCreate(ctx, hostproxy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("spec.nodeSelector matches no node")))
+
+			By("Labelling a node to run the proxy pods")
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "proxy-node",
+					Labels: map[string]string{"networking.raw1z.fr/proxy": "true"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, node)).To(Succeed())
+			warnings, err = validator.ValidateCreate(ctx, hostproxy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+			Expect(k8sClient.Delete(ctx, node)).To(Succeed())
+		})
+	})
+})
@@ -18,7 +18,11 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -40,6 +44,9 @@ import (
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
+
+	// version of the operator, set at build time with -ldflags "-X main.version=..."
+	version = "dev"
 )
 
 func init() {
@@ -50,14 +57,37 @@ func init() {
 }
 
 func main() {
+	// The validate subcommand checks Hostproxy manifests offline instead of running the manager
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validateManifests(os.Args[2:], os.Stdout))
+	}
+
 	var metricsAddr string
+	var secureMetrics bool
+	var metricsCertDir string
 	var enableLeaderElection bool
+	var leaderElectionID string
+	var leaderElectionNamespace string
 	var probeAddr string
+	var reconcileTimeout time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.BoolVar(&secureMetrics, "metrics-secure", false,
+		"Serve the metric endpoint over HTTPS instead of HTTP.")
+	flag.StringVar(&metricsCertDir, "metrics-cert-dir", "",
+		"The directory holding the tls.crt and tls.key files of the metric endpoint. "+
+			"A self-signed certificate is generated when it is empty.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "7a1586f6.raw1z.fr",
+		"The name of the lease used for the leader election. "+
+			"Operators running side by side in a cluster must use distinct leases.")
+	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", "",
+		"The namespace of the lease used for the leader election. "+
+			"It defaults to the namespace the manager runs in.")
+	flag.DurationVar(&reconcileTimeout, "reconcile-timeout", 30*time.Second,
+		"The maximum duration of the reconciliation of a Hostproxy.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -67,11 +97,16 @@ func main() {
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "7a1586f6.raw1z.fr",
+		Scheme: scheme,
+		Metrics: metricsserver.Options{
+			BindAddress:   metricsAddr,
+			SecureServing: secureMetrics,
+			CertDir:       metricsCertDir,
+		},
+		HealthProbeBindAddress:  probeAddr,
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionID:        leaderElectionID,
+		LeaderElectionNamespace: leaderElectionNamespace,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -89,14 +124,74 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The controller can be restricted to a comma separated list of namespaces
+	var watchNamespaces []string
+	if namespaces := os.Getenv("WATCH_NAMESPACE"); namespaces != "" {
+		watchNamespaces = strings.Split(namespaces, ",")
+	}
+
+	// The delay before checking the state of new children can be tuned with a duration like 30s
+	var requeueInterval time.Duration
+	if interval := os.Getenv("REQUEUE_INTERVAL"); interval != "" {
+		if requeueInterval, err = time.ParseDuration(interval); err != nil {
+			setupLog.Error(err, "unable to parse REQUEUE_INTERVAL")
+			os.Exit(1)
+		}
+	}
+
+	// The backoff of the Hostproxies failing repeatedly can be capped with a duration like 1m
+	var maxBackoff time.Duration
+	if backoff := os.Getenv("MAX_BACKOFF"); backoff != "" {
+		if maxBackoff, err = time.ParseDuration(backoff); err != nil {
+			setupLog.Error(err, "unable to parse MAX_BACKOFF")
+			os.Exit(1)
+		}
+	}
+
+	// The reconciliations taking longer than a duration like 5s are logged along with their slowest call
+	var slowReconcileThreshold time.Duration
+	if threshold := os.Getenv("SLOW_RECONCILE_THRESHOLD"); threshold != "" {
+		if slowReconcileThreshold, err = time.ParseDuration(threshold); err != nil {
+			setupLog.Error(err, "unable to parse SLOW_RECONCILE_THRESHOLD")
+			os.Exit(1)
+		}
+	}
+
+	// The host ports are allocated automatically in a range like 40000-40999
+	var hostPortRangeStart, hostPortRangeEnd int32
+	if portRange := os.Getenv("HOST_PORT_RANGE"); portRange != "" {
+		if hostPortRangeStart, hostPortRangeEnd, err = parsePortRange(portRange); err != nil {
+			setupLog.Error(err, "unable to parse HOST_PORT_RANGE")
+			os.Exit(1)
+		}
+	}
+
+	// The finalizer can be disabled so that the Hostproxies can be deleted while the controller is down
+	disableFinalizer := os.Getenv("DISABLE_FINALIZER") == "true"
+
 	if err = (&controller.HostproxyReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("hostproxy-controller"),
+		Client:                 mgr.GetClient(),
+		Scheme:                 mgr.GetScheme(),
+		Recorder:               mgr.GetEventRecorderFor("hostproxy-controller"),
+		WatchNamespaces:        watchNamespaces,
+		ReconcileTimeout:       reconcileTimeout,
+		RequeueInterval:        requeueInterval,
+		MaxBackoff:             maxBackoff,
+		SlowReconcileThreshold: slowReconcileThreshold,
+		HostPortRangeStart:     hostPortRangeStart,
+		HostPortRangeEnd:       hostPortRangeEnd,
+		DisableFinalizer:       disableFinalizer,
+		Version:                version,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Hostproxy")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&networkingv1.Hostproxy{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Hostproxy")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -108,9 +203,29 @@ func main() {
 		os.Exit(1)
 	}
 
-	setupLog.Info("starting manager")
+	setupLog.Info("starting manager", "version", version)
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// parsePortRange parses a range of ports written as start-end
+func parsePortRange(portRange string) (int32, int32, error) {
+	bounds := strings.SplitN(portRange, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("invalid port range %q, expected start-end", portRange)
+	}
+	start, err := strconv.ParseInt(bounds[0], 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := strconv.ParseInt(bounds[1], 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	if start < 1 || end > 65535 || start > end {
+		return 0, 0, fmt.Errorf("invalid port range %q", portRange)
+	}
+	return int32(start), int32(end), nil
+}
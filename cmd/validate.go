@@ -0,0 +1,95 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	networkingv1 "github.com/raw1z/hostproxy/api/v1"
+)
+
+// validateManifests validates the Hostproxies defined in the YAML files at paths, without
+// a cluster, and reports the result of each one to out. The documents of other kinds are
+// ignored. It returns the exit code of the validate subcommand.
+func validateManifests(paths []string, out io.Writer) int {
+	if len(paths) == 0 {
+		fmt.Fprintln(out, "usage: manager validate FILE...")
+		return 2
+	}
+
+	code := 0
+	for _, path := range paths {
+		valid, err := validateManifest(path, out)
+		if err != nil {
+			fmt.Fprintf(out, "%s: %s\n", path, err)
+		}
+		if err != nil || !valid {
+			code = 1
+		}
+	}
+	return code
+}
+
+// validateManifest validates the Hostproxies of a single YAML file. It returns false
+// when one of them is invalid.
+func validateManifest(path string, out io.Writer) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	valid := true
+	reader := yaml.NewYAMLReader(bufio.NewReader(file))
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return false, err
+		}
+
+		typeMeta := metav1.TypeMeta{}
+		if err := sigsyaml.Unmarshal(document, &typeMeta); err != nil {
+			return false, err
+		}
+		if typeMeta.GroupVersionKind() != networkingv1.GroupVersion.WithKind("Hostproxy") {
+			continue
+		}
+
+		// Unknown fields are reported since they are most likely typos
+		hostproxy := networkingv1.Hostproxy{}
+		if err := sigsyaml.UnmarshalStrict(document, &hostproxy); err != nil {
+			return false, err
+		}
+		if err := networkingv1.Validate(hostproxy.Spec); err != nil {
+			fmt.Fprintf(out, "%s: Hostproxy %s is invalid: %s\n", path, hostproxy.Name, err)
+			valid = false
+			continue
+		}
+		fmt.Fprintf(out, "%s: Hostproxy %s is valid\n", path, hostproxy.Name)
+	}
+	return valid, nil
+}
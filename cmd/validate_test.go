@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestValidate(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Validate Suite")
+}
+
+var _ = Describe("validate subcommand", func() {
+	// writeManifest writes a manifest to a temporary file and returns its path
+	writeManifest := func(manifest string) string {
+		path := filepath.Join(GinkgoT().TempDir(), "manifest.yaml")
+		Expect(os.WriteFile(path, []byte(manifest), 0o600)).To(Succeed())
+		return path
+	}
+
+	It("should report the valid Hostproxies of a multi-document manifest and skip other kinds", func() {
+		path := writeManifest(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: unrelated
+---
+apiVersion: networking.raw1z.fr/v1
+kind: Hostproxy
+metadata:
+  name: backend-db
+spec:
+  hostPort: 5432
+  clusterPort: 5432
+---
+apiVersion: networking.raw1z.fr/v1
+kind: Hostproxy
+metadata:
+  name: backend-rpc
+spec:
+  hostPort: 9000
+  clusterPort: 9000
+`)
+
+		out := &bytes.Buffer{}
+		Expect(validateManifests([]string{path}, out)).To(Equal(0))
+		Expect(out.String()).To(Equal(
+			path + ": Hostproxy backend-db is valid\n" +
+				path + ": Hostproxy backend-rpc is valid\n"))
+	})
+
+	It("should report an invalid Hostproxy and fail", func() {
+		path := writeManifest(`
+apiVersion: networking.raw1z.fr/v1
+kind: Hostproxy
+metadata:
+  name: out-of-range
+spec:
+  hostPort: 5432
+  clusterPort: 70000
+`)
+
+		out := &bytes.Buffer{}
+		Expect(validateManifests([]string{path}, out)).To(Equal(1))
+		Expect(out.String()).To(HavePrefix(path + ": Hostproxy out-of-range is invalid: "))
+		Expect(out.String()).To(ContainSubstring("spec.clusterPort"))
+	})
+
+	It("should fail on an unknown field", func() {
+		path := writeManifest(`
+apiVersion: networking.raw1z.fr/v1
+kind: Hostproxy
+metadata:
+  name: typo
+spec:
+  hostPort: 5432
+  clusterPort: 5432
+  hostPorts: 5433
+`)
+
+		out := &bytes.Buffer{}
+		Expect(validateManifests([]string{path}, out)).To(Equal(1))
+		Expect(out.String()).To(ContainSubstring("hostPorts"))
+	})
+
+	It("should fail on a missing file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "missing.yaml")
+
+		out := &bytes.Buffer{}
+		Expect(validateManifests([]string{path}, out)).To(Equal(1))
+		Expect(out.String()).To(HavePrefix(path + ": "))
+	})
+
+	It("should print the usage without any file", func() {
+		out := &bytes.Buffer{}
+		Expect(validateManifests(nil, out)).To(Equal(2))
+		Expect(out.String()).To(ContainSubstring("usage"))
+	})
+})
@@ -0,0 +1,31 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config embeds the manifests of the Hostproxy API and of the permissions of the
+// controller, so that they can be installed without kustomize.
+package config
+
+import "embed"
+
+// CRDs holds the CustomResourceDefinitions generated in crd/bases
+//
+//go:embed crd/bases/*.yaml
+var CRDs embed.FS
+
+// RBAC holds the ClusterRole of the controller generated in rbac/role.yaml
+//
+//go:embed rbac/role.yaml
+var RBAC embed.FS
@@ -0,0 +1,130 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package install applies the Hostproxy CRD and the permissions of the controller to a cluster,
+// so that downstream projects can bootstrap the operator without kustomize or Helm.
+package install
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/raw1z/hostproxy/config"
+)
+
+const (
+	// fieldOwner is the field manager of the applied manifests
+	fieldOwner = "hostproxy-install"
+	// namePrefix is prepended to the name of the ClusterRole, like config/default does
+	namePrefix = "hostproxy-"
+	// establishedTimeout bounds the wait for the CRDs to be served by the API server
+	establishedTimeout = time.Minute
+)
+
+// Install applies the Hostproxy CRD and the ClusterRole of the controller with the given client,
+// then waits until the CRD is established. Binding the ClusterRole to the ServiceAccount running
+// the controller is left to the caller.
+func Install(ctx context.Context, c client.Client) error {
+	crds, err := decodeManifests(config.CRDs, "crd/bases/*.yaml")
+	if err != nil {
+		return err
+	}
+	roles, err := decodeManifests(config.RBAC, "rbac/role.yaml")
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		role.SetName(namePrefix + role.GetName())
+	}
+
+	for _, obj := range append(crds, roles...) {
+		if err := c.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldOwner), client.ForceOwnership); err != nil {
+			return fmt.Errorf("failed to apply %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+
+	for _, crd := range crds {
+		if err := waitForEstablished(ctx, c, crd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeManifests returns the objects defined in the YAML files of fsys matching pattern
+func decodeManifests(fsys fs.FS, pattern string) ([]*unstructured.Unstructured, error) {
+	paths, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []*unstructured.Unstructured
+	for _, path := range paths {
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+		for {
+			obj := &unstructured.Unstructured{}
+			if err := decoder.Decode(&obj.Object); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+			}
+			// Skip the empty documents
+			if len(obj.Object) > 0 {
+				objs = append(objs, obj)
+			}
+		}
+	}
+	return objs, nil
+}
+
+// waitForEstablished waits until the CRD is established, meaning that the API server serves its resources
+func waitForEstablished(ctx context.Context, c client.Client, crd *unstructured.Unstructured) error {
+	err := wait.PollUntilContextTimeout(ctx, time.Second, establishedTimeout, true, func(ctx context.Context) (bool, error) {
+		found := &unstructured.Unstructured{}
+		found.SetGroupVersionKind(crd.GroupVersionKind())
+		if err := c.Get(ctx, client.ObjectKeyFromObject(crd), found); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+
+		conditions, _, _ := unstructured.NestedSlice(found.Object, "status", "conditions")
+		for _, condition := range conditions {
+			condition, ok := condition.(map[string]interface{})
+			if ok && condition["type"] == "Established" && condition["status"] == "True" {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("CRD %s isn't established: %w", crd.GetName(), err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Install", func() {
+	ctx := context.Background()
+
+	It("should establish the Hostproxy CRD and create the ClusterRole of the controller", func() {
+		Expect(Install(ctx, k8sClient)).To(Succeed())
+
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "hostproxies.networking.raw1z.fr"}, crd)).To(Succeed())
+		Expect(crd.Status.Conditions).To(ContainElement(And(
+			HaveField("Type", apiextensionsv1.Established),
+			HaveField("Status", apiextensionsv1.ConditionTrue),
+		)))
+
+		role := &rbacv1.ClusterRole{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "hostproxy-manager-role"}, role)).To(Succeed())
+		Expect(role.Rules).To(Not(BeEmpty()))
+
+		By("Installing again")
+		Expect(Install(ctx, k8sClient)).To(Succeed())
+	})
+})
@@ -18,35 +18,131 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net"
 	"os"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"golang.org/x/time/rate"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	networkingv1 "github.com/raw1z/hostproxy/api/v1"
+	"github.com/raw1z/hostproxy/podtemplate"
 )
 
 const hostproxyFinalizer = "networking.raw1z.fr/finalizer"
 
+// specHashAnnotation records on the Deployment the hash of the spec it has been synced with
+const specHashAnnotation = "networking.raw1z.fr/spec-hash"
+
+// ownerNamespaceLabel is set on the Services created in another namespace than the one of their
+// Hostproxy, which can't own them. Along with the instance label, it names their Hostproxy.
+const ownerNamespaceLabel = "networking.raw1z.fr/owner-namespace"
+
+// backendLabel is set on the Deployments of the backends of a Hostproxy and on their pods
+const backendLabel = "networking.raw1z.fr/backend"
+
+// hostStateDir is the directory of the nodes where the proxies write their state
+const hostStateDir = "/var/lib/hostproxy"
+
+// defaultCleanupDeadlineSeconds is the maximum duration of a cleanup Job when the Hostproxy doesn't set one
+const defaultCleanupDeadlineSeconds int64 = 300
+
+// podDeletionCostAnnotation ranks the pods removed first when a Deployment is scaled down
+const podDeletionCostAnnotation = "controller.kubernetes.io/pod-deletion-cost"
+
+// fieldOwner is the field manager of the resources applied server-side by the controller
+const fieldOwner = "hostproxy-controller"
+
 // Definitions to manage status conditions
 const (
 	// typeAvailableHostproxy represents the status of the Deployment reconciliation
 	typeAvailableHostproxy = "Available"
 	// typeDegradedHostproxy represents the status used when the custom resource is deleted and the finalizer operations are must to occur.
 	typeDegradedHostproxy = "Degraded"
+	// typeSuspendedHostproxy represents the status used when the Hostproxy is scaled to zero replicas.
+	typeSuspendedHostproxy = "Suspended"
+	// typeProgressingHostproxy represents the status of the rollout of the proxy pods.
+	typeProgressingHostproxy = "Progressing"
+	// typePausedHostproxy represents the status used when the reconciliation of the Hostproxy is paused.
+	typePausedHostproxy = "Paused"
+	// typeSelectorConflictHostproxy represents the status used when the selector of the proxy pods matches foreign pods.
+	typeSelectorConflictHostproxy = "SelectorConflict"
+)
+
+// defaultReconcileTimeout is the maximum duration of a reconciliation when none is configured
+const defaultReconcileTimeout = 30 * time.Second
+
+// deploymentProgressDeadlineExceeded is the reason of the Progressing condition of a Deployment
+// whose rollout has exceeded its deadline
+const deploymentProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+// defaultSlowReconcileThreshold is the duration above which a reconciliation is reported as slow
+// when none is configured
+const defaultSlowReconcileThreshold = 2 * time.Second
+
+// defaultRequeueInterval is the delay before the state of new children is checked when none is configured
+const defaultRequeueInterval = time.Minute
+
+// defaultRestartThreshold is the number of restarts of the proxy containers above which a
+// Hostproxy is degraded when it doesn't set its own threshold
+const defaultRestartThreshold int32 = 5
+
+// maxRecentEvents is the number of events kept in the status of a Hostproxy, which matches the
+// maximum length of the list in the CRD
+const maxRecentEvents = 10
+
+// conflictRequeueInterval is the delay before a reconciliation which failed on a conflicting
+// update is retried
+const conflictRequeueInterval = time.Second
+
+// notReadyRequeueInterval is the delay before the state of the proxy pods is checked again while
+// some of them aren't ready
+const notReadyRequeueInterval = 5 * time.Second
+
+// Definitions of the range in which the host ports are allocated when none is configured
+const (
+	defaultHostPortRangeStart = 40000
+	defaultHostPortRangeEnd   = 40999
+)
+
+// Definitions used to throttle the reconciliation of Hostproxy resources
+const (
+	// rateLimiterBaseDelay is the delay before the first retry of a failing Hostproxy
+	rateLimiterBaseDelay = 500 * time.Millisecond
+	// rateLimiterMaxDelay caps the exponential backoff applied to a failing Hostproxy when no
+	// other ceiling is configured
+	rateLimiterMaxDelay = 5 * time.Minute
 )
 
 // HostproxyReconciler reconciles a Hostproxy object
@@ -54,6 +150,37 @@ type HostproxyReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+
+	// WatchNamespaces restricts the reconciliation to the Hostproxies living in these namespaces.
+	// All the namespaces are reconciled when it is empty.
+	WatchNamespaces []string
+
+	// ReconcileTimeout bounds the duration of a reconciliation. It defaults to 30 seconds.
+	ReconcileTimeout time.Duration
+
+	// MaxBackoff caps the delay before retrying a Hostproxy whose reconciliations keep failing, so
+	// that it recovers quickly once the problem is fixed. It defaults to 5 minutes.
+	MaxBackoff time.Duration
+
+	// SlowReconcileThreshold is the duration above which a reconciliation is logged as slow,
+	// along with its slowest call to the API server. It defaults to 2 seconds.
+	SlowReconcileThreshold time.Duration
+
+	// RequeueInterval is the delay before a reconciliation is requeued to check the state of
+	// the children it has just created. It defaults to 1 minute.
+	RequeueInterval time.Duration
+
+	// HostPortRangeStart and HostPortRangeEnd bound the range in which the host ports of the
+	// Hostproxies requesting an automatic allocation are picked. They default to 40000-40999.
+	HostPortRangeStart int32
+	HostPortRangeEnd   int32
+
+	// DisableFinalizer lets the Hostproxies be deleted without waiting for the controller, which
+	// then can't clean up the state of the proxy on the nodes
+	DisableFinalizer bool
+
+	// Version of the operator, which is reported in the status of the Hostproxies it reconciles
+	Version string
 }
 
 // The following markers are used to generate the rules permissions (RBAC) on config/rbac using controller-gen
@@ -65,8 +192,16 @@ type HostproxyReconciler struct {
 //+kubebuilder:rbac:groups=networking.raw1z.fr,resources=hostproxies/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=core,resources=services,verbs=list;watch;get;patch;create;update
-//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=services,verbs=list;watch;get;patch;create;update;delete
+//+kubebuilder:rbac:groups=core,resources=endpoints,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -80,8 +215,68 @@ type HostproxyReconciler struct {
 // - About Controllers: https://kubernetes.io/docs/concepts/architecture/controller/
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.16.3/pkg/reconcile
 func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// The identity of the Hostproxy is added to all the logs of the reconciliation, including the
+	// ones of the helpers getting their logger from the context
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues("hostproxy", req.NamespacedName))
+	log := log.FromContext(ctx)
+	start := time.Now()
+
+	// Bound the duration of the reconciliation, so that a slow API server can't
+	// hang a worker indefinitely
+	timeout := r.ReconcileTimeout
+	if timeout == 0 {
+		timeout = defaultReconcileTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The slowest call to the API server is recorded to explain the slow reconciliations
+	tracker := &apiCallTracker{}
+	result, err := r.reconcile(withAPICallTracker(ctx, tracker), req)
+	if ctx.Err() != nil {
+		// The reconciliation has been interrupted, so it is requeued instead of being reported as a failure
+		log.Info("Reconciliation interrupted, requeuing", "reason", ctx.Err().Error())
+		result, err = ctrl.Result{Requeue: true}, nil
+	} else if apierrors.IsConflict(err) {
+		// The Hostproxy or one of its children has been modified concurrently, which is retried
+		// shortly with their latest version rather than being reported as a failure. The conflicts
+		// are returned as they are by the updates, without being logged nor reported in the status.
+		log.V(1).Info("Conflicting update, requeuing", "reason", err.Error())
+		result, err = ctrl.Result{RequeueAfter: conflictRequeueInterval}, nil
+	} else if namespaceTerminating(err) {
+		// Nothing can be created in a terminating namespace, so retrying would only spin until the
+		// namespace is gone, taking the Hostproxy with it
+		log.Info("Namespace terminating, stopping the reconciliation", "reason", err.Error())
+		result, err = ctrl.Result{}, nil
+	}
+
+	elapsed := time.Since(start)
+	threshold := r.SlowReconcileThreshold
+	if threshold == 0 {
+		threshold = defaultSlowReconcileThreshold
+	}
+	if elapsed > threshold {
+		// A slow reconciliation isn't an error, so it is logged at the default level with the slow
+		// key, by which it can be filtered
+		call, callDuration := tracker.slowest()
+		log.V(0).Info("Slow reconciliation", "slow", true, "elapsed", elapsed.String(), "threshold", threshold.String(),
+			"slowestCall", call, "slowestCallDuration", callDuration.String())
+	}
+
+	observeReconcileDuration(elapsed, result, err)
+	return result, err
+}
+
+// reconcile performs the reconciliation of a Hostproxy within the deadline set by Reconcile
+func (r *HostproxyReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
+	// Ignore the Hostproxies living outside of the namespaces this controller is responsible for
+	if !r.watchesNamespace(req.Namespace) {
+		log.Info("hostproxy resource is outside of the watched namespaces. Ignoring")
+		return ctrl.Result{}, nil
+	}
+
 	// Fetch the Hostproxy instance
 	// The purpose is check if the Custom Resource for the Kind Hostproxy
 	// is applied on the cluster if not we return nil to stop the reconciliation
@@ -101,8 +296,11 @@ func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 	// Let's just set the status as Unknown when no status are available
 	if hostproxy.Status.Conditions == nil || len(hostproxy.Status.Conditions) == 0 {
-		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy, Status: metav1.ConditionUnknown, Reason: "Reconciling", Message: "Starting reconciliation"})
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy, Status: metav1.ConditionUnknown, Reason: networkingv1.ReasonReconciling, Message: "Starting reconciliation", ObservedGeneration: hostproxy.Generation})
 		if err = r.Status().Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
 			log.Error(err, "Failed to update Hostproxy status")
 			return ctrl.Result{}, err
 		}
@@ -121,7 +319,20 @@ func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	// Let's add a finalizer. Then, we can define some operations which should
 	// occurs before the custom resource to be deleted.
 	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/finalizers
-	if !controllerutil.ContainsFinalizer(hostproxy, hostproxyFinalizer) {
+	if r.DisableFinalizer {
+		// The finalizer added before it was disabled is removed, so that the deletion of the
+		// Hostproxy doesn't depend on the controller anymore
+		if hostproxy.GetDeletionTimestamp() == nil && controllerutil.RemoveFinalizer(hostproxy, hostproxyFinalizer) {
+			log.Info("Removing the disabled Finalizer for Hostproxy")
+			if err = r.Update(ctx, hostproxy); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
+				log.Error(err, "Failed to update custom resource to remove finalizer")
+				return ctrl.Result{}, err
+			}
+		}
+	} else if !controllerutil.ContainsFinalizer(hostproxy, hostproxyFinalizer) {
 		log.Info("Adding Finalizer for Hostproxy")
 		if ok := controllerutil.AddFinalizer(hostproxy, hostproxyFinalizer); !ok {
 			log.Error(err, "Failed to add finalizer into the custom resource")
@@ -129,6 +340,9 @@ func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 
 		if err = r.Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
 			log.Error(err, "Failed to update custom resource to add finalizer")
 			return ctrl.Result{}, err
 		}
@@ -143,21 +357,44 @@ func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 			// Let's add here an status "Downgrade" to define that this resource begin its process to be terminated.
 			meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeDegradedHostproxy,
-				Status: metav1.ConditionUnknown, Reason: "Finalizing",
+				Status: metav1.ConditionUnknown, Reason: networkingv1.ReasonFinalizing, ObservedGeneration: hostproxy.Generation,
 				Message: fmt.Sprintf("Performing finalizer operations for the custom resource: %s ", hostproxy.Name)})
 
 			if err := r.Status().Update(ctx, hostproxy); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
 				log.Error(err, "Failed to update Hostproxy status")
 				return ctrl.Result{}, err
 			}
 
-			// Perform all operations required before remove the finalizer and allow
-			// the Kubernetes API to remove the custom resource.
-			r.doFinalizerOperationsForHostproxy(hostproxy)
+			// The Services in other namespaces aren't garbage collected with the Hostproxy
+			if err := r.deleteCrossNamespaceServices(ctx, hostproxy, types.NamespacedName{}); err != nil {
+				log.Error(err, "Failed to delete the Services in other namespaces")
+				return ctrl.Result{}, err
+			}
 
-			// TODO(user): If you add operations to the doFinalizerOperationsForHostproxy method
-			// then you need to ensure that all worked fine before deleting and updating the Downgrade status
-			// otherwise, you should requeue here.
+			// Remove the state written by the proxy on the nodes before letting the Hostproxy go
+			if hostproxy.Spec.CleanupHostState {
+				done, err := r.cleanupHostState(ctx, hostproxy)
+				if err != nil {
+					log.Error(err, "Failed to clean up the state of the proxy on the nodes")
+					return ctrl.Result{}, err
+				}
+
+				// The failed cleanups are kept in the recent events
+				if err := r.Status().Update(ctx, hostproxy); err != nil {
+					if apierrors.IsConflict(err) {
+						return ctrl.Result{}, err
+					}
+					log.Error(err, "Failed to update Hostproxy status")
+					return ctrl.Result{}, err
+				}
+				if !done {
+					log.Info("Waiting for the cleanup Jobs to complete")
+					return ctrl.Result{RequeueAfter: notReadyRequeueInterval}, nil
+				}
+			}
 
 			// Re-fetch the hostproxy Custom Resource before update the status
 			// so that we have the latest state of the resource on the cluster and we will avoid
@@ -168,11 +405,23 @@ func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 				return ctrl.Result{}, err
 			}
 
+			// Perform all operations required before remove the finalizer and allow
+			// the Kubernetes API to remove the custom resource. The event they raise
+			// is kept in the recent events by the following status update.
+			r.doFinalizerOperationsForHostproxy(hostproxy)
+
+			// TODO(user): If you add operations to the doFinalizerOperationsForHostproxy method
+			// then you need to ensure that all worked fine before deleting and updating the Downgrade status
+			// otherwise, you should requeue here.
+
 			meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeDegradedHostproxy,
-				Status: metav1.ConditionTrue, Reason: "Finalizing",
+				Status: metav1.ConditionTrue, Reason: networkingv1.ReasonFinalizing, ObservedGeneration: hostproxy.Generation,
 				Message: fmt.Sprintf("Finalizer operations for custom resource %s name were successfully accomplished", hostproxy.Name)})
 
 			if err := r.Status().Update(ctx, hostproxy); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
 				log.Error(err, "Failed to update Hostproxy status")
 				return ctrl.Result{}, err
 			}
@@ -184,6 +433,9 @@ func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			}
 
 			if err := r.Update(ctx, hostproxy); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
 				log.Error(err, "Failed to remove finalizer for Hostproxy")
 				return ctrl.Result{}, err
 			}
@@ -191,9 +443,248 @@ func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
+	// A Hostproxy without ports can't proxy anything, so no children are created for it. The
+	// validating webhook rejects such resources, but it may be disabled.
+	if hostproxy.Spec.ClusterPort == 0 ||
+		(hostproxy.Spec.HostPort == 0 && !hostproxy.Spec.AutoAllocate && len(hostproxy.Spec.Backends) == 0) {
+		log.Info("hostproxy resource has no ports. Skipping the creation of its children")
+
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeDegradedHostproxy,
+			Status: metav1.ConditionTrue, Reason: networkingv1.ReasonMissingPorts, ObservedGeneration: hostproxy.Generation,
+			Message: "The host port and the cluster port of the Hostproxy must be set"})
+
+		if err := r.Status().Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update Hostproxy status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.requeueInterval()}, nil
+	}
+	if condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeDegradedHostproxy); condition != nil &&
+		condition.Reason == networkingv1.ReasonMissingPorts {
+		meta.RemoveStatusCondition(&hostproxy.Status.Conditions, typeDegradedHostproxy)
+	}
+
+	// Leave the children untouched while the Hostproxy is paused for maintenance
+	if hostproxy.Annotations[networkingv1.PausedAnnotation] == "true" {
+		log.Info("hostproxy resource is paused. Skipping the reconciliation of its children")
+
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typePausedHostproxy,
+			Status: metav1.ConditionTrue, Reason: networkingv1.ReasonPaused, ObservedGeneration: hostproxy.Generation,
+			Message: fmt.Sprintf("Reconciliation paused by the %s annotation", networkingv1.PausedAnnotation)})
+
+		if err := r.Status().Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update Hostproxy status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&hostproxy.Status.Conditions, typePausedHostproxy)
+
+	// Pick a free host port when the Hostproxy doesn't set one, before it is passed to the proxy
+	if hostproxy.Spec.AutoAllocate && hostproxy.Spec.HostPort == 0 && hostproxy.Status.AllocatedHostPort == 0 &&
+		len(hostproxy.Spec.Backends) == 0 {
+		port, err := r.allocateHostPort(ctx)
+		if err != nil {
+			log.Error(err, "Failed to allocate a host port")
+
+			// The details of the error are only reported in the event, so that the condition stays stable
+			r.recordEvent(hostproxy, "Warning", networkingv1.ReasonPortConflict, err.Error())
+			meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+				Status: metav1.ConditionFalse, Reason: networkingv1.ReasonPortConflict, ObservedGeneration: hostproxy.Generation,
+				Message: fmt.Sprintf("Failed to allocate a host port for the custom resource (%s)", hostproxy.Name)})
+
+			if err := r.Status().Update(ctx, hostproxy); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
+				log.Error(err, "Failed to update Hostproxy status")
+				return ctrl.Result{}, err
+			}
+
+			return ctrl.Result{}, err
+		}
+
+		log.Info("Allocated a host port", "HostPort", port)
+		hostproxy.Status.AllocatedHostPort = port
+		if err := r.Status().Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update Hostproxy status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// The checksums of the configuration and of the credentials are computed before the templates
+	// of the proxy pods
+	if err = r.setChecksums(ctx, hostproxy); err != nil {
+		log.Error(err, "Failed to get the ConfigMap or the Secret mounted in the proxy pods")
+		return ctrl.Result{}, err
+	}
+
+	// In DaemonSet mode, a proxy pod runs on every node instead of the pods of a Deployment
+	if hostproxy.Spec.Mode == networkingv1.DaemonSetMode {
+		return r.reconcileDaemonSetMode(ctx, hostproxy)
+	}
+
+	// Remove the DaemonSet left by the DaemonSet mode
+	if err = r.deleteControlledObject(ctx, hostproxy, hostproxy.Name, &appsv1.DaemonSet{}); err != nil {
+		log.Error(err, "Failed to delete the DaemonSet")
+		return ctrl.Result{}, err
+	}
+
+	// The Service is reconciled before the Deployment, so that the names it publishes in the DNS
+	// can be resolved as soon as the proxy pods start
+	if wantsService(hostproxy) {
+		// Define the desired service
+		svc, err := r.serviceForHostproxy(hostproxy)
+		if err != nil {
+			log.Error(err, "Failed to define new Service resource for Hostproxy")
+
+			// The following implementation will update the status
+			r.recordEvent(hostproxy, "Warning", networkingv1.ReasonServiceFailed, err.Error())
+			meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+				Status: metav1.ConditionFalse, Reason: networkingv1.ReasonServiceFailed, ObservedGeneration: hostproxy.Generation,
+				Message: fmt.Sprintf("Failed to create Service for the custom resource (%s)", hostproxy.Name)})
+
+			if err := r.Status().Update(ctx, hostproxy); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
+				log.Error(err, "Failed to update Hostproxy status")
+				return ctrl.Result{}, err
+			}
+
+			return ctrl.Result{}, err
+		}
+
+		// The Service of a former Hostproxy can't be applied since it is already controlled by it
+		foundService := &corev1.Service{}
+		if err = r.Get(ctx, client.ObjectKeyFromObject(svc), foundService); err == nil && ownedByStaleHostproxy(foundService, hostproxy) {
+			log.Info("Recreating the Service of a former Hostproxy",
+				"Service.Namespace", foundService.Namespace, "Service.Name", foundService.Name)
+			if err = r.Delete(ctx, foundService); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "Failed to delete Service", "Service.Namespace", foundService.Namespace, "Service.Name", foundService.Name)
+				return ctrl.Result{}, err
+			}
+		} else if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get Service", "Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+			return ctrl.Result{}, err
+		} else if err == nil && svc.Namespace != hostproxy.Namespace && !managesCrossNamespaceService(hostproxy, foundService) {
+			// A Service of another namespace can't be told apart by its controller, so the ones the
+			// Hostproxy doesn't manage are left untouched
+			err = fmt.Errorf("the Service %s already exists in the namespace %s", svc.Name, svc.Namespace)
+			log.Error(err, "Failed to apply the Service")
+			r.recordEvent(hostproxy, "Warning", networkingv1.ReasonServiceFailed, err.Error())
+			meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+				Status: metav1.ConditionFalse, Reason: networkingv1.ReasonServiceFailed, ObservedGeneration: hostproxy.Generation,
+				Message: fmt.Sprintf("Failed to create Service for the custom resource (%s)", hostproxy.Name)})
+
+			if err := r.Status().Update(ctx, hostproxy); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
+				log.Error(err, "Failed to update Hostproxy status")
+				return ctrl.Result{}, err
+			}
+
+			return ctrl.Result{}, err
+		}
+
+		// The Service is applied server-side, so that the controller only owns the fields it
+		// manages and doesn't conflict with the other controllers updating the Service, for
+		// instance a service mesh injecting its annotations. The selector is an atomic map, so
+		// the apply replaces the keys which have been edited or added by the other field managers.
+		if err = r.Patch(ctx, svc, client.Apply, client.FieldOwner(fieldOwner), client.ForceOwnership); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to apply the Service", "Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+			return ctrl.Result{}, err
+		}
+
+		// The Service of another namespace can't select the proxy pods, which are listed in its
+		// endpoints instead
+		if svc.Namespace != hostproxy.Namespace {
+			if err = r.reconcileEndpoints(ctx, hostproxy, svc); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
+				log.Error(err, "Failed to reconcile the Endpoints of the Service",
+					"Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+				return ctrl.Result{}, err
+			}
+		}
+
+		// The applied Service carries the node port allocated by the API server
+		hostproxy.Status.ServiceName = svc.Name
+		hostproxy.Status.NodePort = 0
+		if len(svc.Spec.Ports) > 0 {
+			hostproxy.Status.NodePort = svc.Spec.Ports[0].NodePort
+		}
+	} else {
+		hostproxy.Status.ServiceName = ""
+		hostproxy.Status.NodePort = 0
+	}
+
+	// Remove the Services owned by the Hostproxy which are not wanted anymore, either because
+	// the Service is disabled or because it has been renamed or moved to another namespace
+	services := &corev1.ServiceList{}
+	if err = r.List(ctx, services, client.InNamespace(hostproxy.Namespace)); err != nil {
+		log.Error(err, "Failed to list Services")
+		// Let's return the error for the reconciliation be re-trigged again
+		return ctrl.Result{}, err
+	}
+	for i := range services.Items {
+		foundService := &services.Items[i]
+		if !metav1.IsControlledBy(foundService, hostproxy) ||
+			(wantsService(hostproxy) && foundService.Name == serviceNameForHostproxy(hostproxy) &&
+				serviceNamespaceForHostproxy(hostproxy) == hostproxy.Namespace) {
+			continue
+		}
+		log.Info("Deleting the Service", "Service.Namespace", foundService.Namespace, "Service.Name", foundService.Name)
+		if err = r.Delete(ctx, foundService); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to delete Service", "Service.Namespace", foundService.Namespace, "Service.Name", foundService.Name)
+			return ctrl.Result{}, err
+		}
+	}
+	var crossNamespaceService types.NamespacedName
+	if wantsService(hostproxy) {
+		crossNamespaceService = types.NamespacedName{Name: serviceNameForHostproxy(hostproxy),
+			Namespace: serviceNamespaceForHostproxy(hostproxy)}
+	}
+	if err = r.deleteCrossNamespaceServices(ctx, hostproxy, crossNamespaceService); err != nil {
+		log.Error(err, "Failed to delete the Services in other namespaces")
+		return ctrl.Result{}, err
+	}
+
+	// With several backends, the replicas are split between a Deployment per backend
+	if len(hostproxy.Spec.Backends) > 0 {
+		return r.reconcileBackends(ctx, hostproxy)
+	}
+
+	// Remove the Deployments left by the backends
+	if err = r.deleteBackendDeployments(ctx, hostproxy, nil); err != nil {
+		log.Error(err, "Failed to delete the Deployments of the backends")
+		return ctrl.Result{}, err
+	}
+
+	// Remove the Deployment left under its former name when the name suffix has changed
+	if err = r.deleteRenamedDeployments(ctx, hostproxy); err != nil {
+		log.Error(err, "Failed to delete the renamed Deployments")
+		return ctrl.Result{}, err
+	}
+
 	// Check if the deployment already exists, if not create a new one
+	deploymentName := deploymentNameForHostproxy(hostproxy)
 	found := &appsv1.Deployment{}
-	err = r.Get(ctx, types.NamespacedName{Name: hostproxy.Name, Namespace: hostproxy.Namespace}, found)
+	err = r.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: hostproxy.Namespace}, found)
 	if err != nil && apierrors.IsNotFound(err) {
 		// Define a new deployment
 		dep, err := r.deploymentForHostproxy(hostproxy)
@@ -201,11 +692,15 @@ func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			log.Error(err, "Failed to define new Deployment resource for Hostproxy")
 
 			// The following implementation will update the status
+			r.recordEvent(hostproxy, "Warning", networkingv1.ReasonDeploymentFailed, err.Error())
 			meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
-				Status: metav1.ConditionFalse, Reason: "Reconciling",
-				Message: fmt.Sprintf("Failed to create Deployment for the custom resource (%s): (%s)", hostproxy.Name, err)})
+				Status: metav1.ConditionFalse, Reason: networkingv1.ReasonDeploymentFailed, ObservedGeneration: hostproxy.Generation,
+				Message: fmt.Sprintf("Failed to create Deployment for the custom resource (%s)", hostproxy.Name)})
 
 			if err := r.Status().Update(ctx, hostproxy); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
 				log.Error(err, "Failed to update Hostproxy status")
 				return ctrl.Result{}, err
 			}
@@ -219,61 +714,164 @@ func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			return ctrl.Result{}, err
 		}
 
+		addRecentEvent(hostproxy, "Normal", networkingv1.ReasonDeploymentCreated,
+			fmt.Sprintf("Created the Deployment %s", dep.Name))
+		if err := r.Status().Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update Hostproxy status")
+			return ctrl.Result{}, err
+		}
+
 		// Deployment created successfully
 		// We will requeue the reconciliation so that we can ensure the state
 		// and move forward for the next operations
-		return ctrl.Result{RequeueAfter: time.Minute}, nil
+		return ctrl.Result{RequeueAfter: r.requeueInterval()}, nil
 	} else if err != nil {
-		log.Error(err, "Failed to get Deployment")
+		log.Error(err, "Failed to get Deployment", "Deployment.Namespace", hostproxy.Namespace, "Deployment.Name", deploymentName)
 		// Let's return the error for the reconciliation be re-trigged again
 		return ctrl.Result{}, err
 	}
 
-	foundService := &corev1.Service{}
-	err = r.Get(ctx, types.NamespacedName{Name: hostproxy.Name, Namespace: hostproxy.Namespace}, foundService)
-	if err != nil && apierrors.IsNotFound(err) {
-		// Define a new service
-		svc, err := r.serviceForHostproxy(hostproxy)
-		if err != nil {
-			log.Error(err, "Failed to define new Service resource for Hostproxy")
+	// A Deployment left by a former Hostproxy of the same name, deleted and created again before the
+	// garbage collector removed its children, is recreated instead of being updated
+	if ownedByStaleHostproxy(found, hostproxy) {
+		log.Info("Recreating the Deployment of a former Hostproxy",
+			"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+		if err = r.Delete(ctx, found, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to delete Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
 
-			// The following implementation will update the status
-			meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
-				Status: metav1.ConditionFalse, Reason: "Reconciling",
-				Message: fmt.Sprintf("Failed to create Service for the custom resource (%s): (%s)", hostproxy.Name, err)})
+	// A Deployment created before the Hostproxy, for instance by hand before the operator was
+	// installed, is adopted so that it is managed and garbage collected with the Hostproxy
+	if err = r.adoptObject(ctx, hostproxy, found); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to adopt Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+		return ctrl.Result{}, err
+	}
 
-			if err := r.Status().Update(ctx, hostproxy); err != nil {
-				log.Error(err, "Failed to update Hostproxy status")
+	// The selector of a Deployment is immutable, so a Deployment selecting other labels than
+	// the desired ones can't be updated and is recreated instead
+	if metav1.IsControlledBy(found, hostproxy) && !selectsHostproxy(found.Spec.Selector, hostproxy.Name) {
+		log.Info("Recreating the Deployment since its selector has changed",
+			"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+		if err = r.Delete(ctx, found, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to delete Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+			return ctrl.Result{}, err
+		}
+
+		// The Deployment is created again by the next reconciliation
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// The proxy pods may run with a ServiceAccount dedicated to the Hostproxy
+	if err = r.reconcileServiceAccount(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to reconcile the ServiceAccount")
+
+		// The following implementation will update the status
+		r.recordEvent(hostproxy, "Warning", networkingv1.ReasonServiceAccountFailed, err.Error())
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+			Status: metav1.ConditionFalse, Reason: networkingv1.ReasonServiceAccountFailed, ObservedGeneration: hostproxy.Generation,
+			Message: fmt.Sprintf("Failed to reconcile the ServiceAccount for the custom resource (%s)", hostproxy.Name)})
+
+		if err := r.Status().Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
 				return ctrl.Result{}, err
 			}
+			log.Error(err, "Failed to update Hostproxy status")
+			return ctrl.Result{}, err
+		}
 
+		return ctrl.Result{}, err
+	}
+
+	// Multi-replica proxies are protected from simultaneous evictions by a PodDisruptionBudget
+	if err = r.reconcilePodDisruptionBudget(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
 			return ctrl.Result{}, err
 		}
+		log.Error(err, "Failed to reconcile the PodDisruptionBudget")
+
+		// The following implementation will update the status
+		r.recordEvent(hostproxy, "Warning", networkingv1.ReasonPodDisruptionBudgetFailed, err.Error())
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+			Status: metav1.ConditionFalse, Reason: networkingv1.ReasonPodDisruptionBudgetFailed, ObservedGeneration: hostproxy.Generation,
+			Message: fmt.Sprintf("Failed to reconcile the PodDisruptionBudget for the custom resource (%s)", hostproxy.Name)})
 
-		log.Info("Creating a new Service", "Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
-		if err = r.Create(ctx, svc); err != nil {
-			log.Error(err, "Failed to create new Service", "Deployment.Namespace", svc.Namespace, "Deployment.Name", svc.Name)
+		if err := r.Status().Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update Hostproxy status")
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	// The access to the proxy pods may be restricted to some sources by a NetworkPolicy
+	if err = r.reconcileNetworkPolicy(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to reconcile the NetworkPolicy")
+
+		// The following implementation will update the status
+		r.recordEvent(hostproxy, "Warning", networkingv1.ReasonNetworkPolicyFailed, err.Error())
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+			Status: metav1.ConditionFalse, Reason: networkingv1.ReasonNetworkPolicyFailed, ObservedGeneration: hostproxy.Generation,
+			Message: fmt.Sprintf("Failed to reconcile the NetworkPolicy for the custom resource (%s)", hostproxy.Name)})
+
+		if err := r.Status().Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update Hostproxy status")
 			return ctrl.Result{}, err
 		}
 
-		// Service created successfully
-		// We will requeue the reconciliation so that we can ensure the state
-		// and move forward for the next operations
-		return ctrl.Result{RequeueAfter: time.Minute}, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get Service")
-		// Let's return the error for the reconciliation be re-trigged again
 		return ctrl.Result{}, err
 	}
 
-	// The CRD API is defining that the Hostproxy type, have a HostproxySpec.Size field
+	// The CRD API is defining that the Hostproxy type, have a HostproxySpec.Replicas field
 	// to set the quantity of Deployment instances is the desired state on the cluster.
 	// Therefore, the following code will ensure the Deployment size is the same as defined
-	// via the Size spec of the Custom Resource which we are reconciling.
-	size := int32(1)
+	// via the Replicas spec of the Custom Resource which we are reconciling.
+	size := podtemplate.Replicas(hostproxy)
 	if *found.Spec.Replicas != size {
+		// Wait for the proxy pods which are going to be removed to drain their connections
+		if hostproxy.Spec.DrainOnScaleDown && size < *found.Spec.Replicas {
+			drained, err := r.drainProxyPods(ctx, hostproxy, int(*found.Spec.Replicas-size))
+			if err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
+				log.Error(err, "Failed to drain the proxy pods")
+				return ctrl.Result{}, err
+			}
+			if !drained {
+				log.Info("Waiting for the proxy pods to drain before scaling down",
+					"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+				return ctrl.Result{RequeueAfter: r.requeueInterval()}, nil
+			}
+		}
+
+		previous := found.Spec.Replicas
 		found.Spec.Replicas = &size
 		if err = r.Update(ctx, found); err != nil {
+			// A conflict doesn't mean the Deployment can't be updated, so it isn't reported
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
 			log.Error(err, "Failed to update Deployment",
 				"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
 
@@ -287,11 +885,15 @@ func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			}
 
 			// The following implementation will update the status
+			r.recordEvent(hostproxy, "Warning", networkingv1.ReasonResizing, err.Error())
 			meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
-				Status: metav1.ConditionFalse, Reason: "Resizing",
-				Message: fmt.Sprintf("Failed to update the size for the custom resource (%s): (%s)", hostproxy.Name, err)})
+				Status: metav1.ConditionFalse, Reason: networkingv1.ReasonResizing, ObservedGeneration: hostproxy.Generation,
+				Message: fmt.Sprintf("Failed to update the size for the custom resource (%s)", hostproxy.Name)})
 
 			if err := r.Status().Update(ctx, hostproxy); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
 				log.Error(err, "Failed to update Hostproxy status")
 				return ctrl.Result{}, err
 			}
@@ -299,28 +901,173 @@ func (r *HostproxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			return ctrl.Result{}, err
 		}
 
+		message := fmt.Sprintf("Scaled the Deployment %s to %d replicas", found.Name, size)
+		if previous != nil {
+			message = fmt.Sprintf("Scaled the Deployment %s from %d to %d replicas", found.Name, *previous, size)
+		}
+		addRecentEvent(hostproxy, "Normal", networkingv1.ReasonScaled, message)
+		if err := r.Status().Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update Hostproxy status")
+			return ctrl.Result{}, err
+		}
+
 		// Now, that we update the size we want to requeue the reconciliation
 		// so that we can ensure that we have the latest state of the resource before
 		// update. Also, it will help ensure the desired state on the cluster
 		return ctrl.Result{Requeue: true}, nil
 	}
 
-	// The following implementation will update the status
-	meta.SetStatusCondition(
-		&hostproxy.Status.Conditions,
-		metav1.Condition{
-			Type:   typeAvailableHostproxy,
-			Status: metav1.ConditionTrue, Reason: "Reconciling",
-			Message: fmt.Sprintf("Deployment for custom resource (%s) with %d replicas created successfully", hostproxy.Name, size),
-		},
-	)
+	if size == 0 {
+		// A Hostproxy scaled to zero is suspended: there is no proxy pod to check
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeSuspendedHostproxy,
+			Status: metav1.ConditionTrue, Reason: networkingv1.ReasonScaledToZero, ObservedGeneration: hostproxy.Generation,
+			Message: "The Hostproxy is suspended since it has no replicas"})
+		meta.RemoveStatusCondition(&hostproxy.Status.Conditions, typeDegradedHostproxy)
+	} else {
+		meta.RemoveStatusCondition(&hostproxy.Status.Conditions, typeSuspendedHostproxy)
 
-	if err := r.Status().Update(ctx, hostproxy); err != nil {
-		log.Error(err, "Failed to update Hostproxy status")
-		return ctrl.Result{}, err
-	}
+		previousReason := degradedReason(hostproxy)
+		if err = r.setDegradedCondition(ctx, hostproxy); err != nil {
+			log.Error(err, "Failed to list the proxy pods")
+			return ctrl.Result{}, err
+		}
 
-	return ctrl.Result{}, nil
+		// A rollout stuck beyond its deadline is reported by the Deployment, unless the proxy pods
+		// already tell why
+		if condition := deploymentCondition(found, appsv1.DeploymentProgressing); condition != nil &&
+			condition.Reason == deploymentProgressDeadlineExceeded &&
+			!meta.IsStatusConditionTrue(hostproxy.Status.Conditions, typeDegradedHostproxy) {
+			if previousReason != networkingv1.ReasonProgressDeadlineExceeded {
+				r.recordEvent(hostproxy, "Warning", networkingv1.ReasonProgressDeadlineExceeded, condition.Message)
+			}
+			meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeDegradedHostproxy,
+				Status: metav1.ConditionTrue, Reason: networkingv1.ReasonProgressDeadlineExceeded, ObservedGeneration: hostproxy.Generation,
+				Message: condition.Message})
+		}
+	}
+
+	// Report the child resources managed for this Hostproxy
+	hostproxy.Status.OwnedResources = ownedResourcesForHostproxy(hostproxy)
+
+	// Report the proxy image which has been resolved for this Hostproxy
+	if image, err := imageForHostproxy(); err == nil {
+		hostproxy.Status.Image = image
+		hostproxy.Status.ImageVersion = podtemplate.ImageVersion(image)
+	}
+
+	// Ensure the fields of the Deployment managed by the controller match the spec.
+	// Any change of the pod template triggers a rollout, so that the proxy restarts
+	// with the new settings. The comparison is skipped as long as the hash of the
+	// desired spec matches the one recorded on the Deployment.
+	desired, err := r.deploymentForHostproxy(hostproxy)
+	if err != nil {
+		log.Error(err, "Failed to define the desired Deployment resource for Hostproxy")
+		return ctrl.Result{}, err
+	}
+	hash := desired.Annotations[specHashAnnotation]
+	malformed := len(found.Spec.Template.Spec.Containers) == 0
+	if malformed || found.Annotations[specHashAnnotation] != hash ||
+		proxyContainerDrifted(&found.Spec.Template, &desired.Spec.Template) {
+		if malformed {
+			// The pod template has lost its containers, for instance because of a faulty
+			// admission controller, so it is rebuilt from scratch
+			log.Info("Repairing the malformed Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+			found.Spec.Template = desired.Spec.Template
+		} else {
+			syncDeployment(found, desired)
+		}
+		metav1.SetMetaDataAnnotation(&found.ObjectMeta, specHashAnnotation, hash)
+
+		log.Info("Updating the Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+		if err = r.Update(ctx, found); err != nil {
+			// A conflict doesn't mean the Deployment can't be updated, so it isn't reported
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update Deployment",
+				"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+
+			// Re-fetch the hostproxy Custom Resource before update the status
+			// so that we have the latest state of the resource on the cluster and we will avoid
+			// raise the issue "the object has been modified, please apply
+			// your changes to the latest version and try again" which would re-trigger the reconciliation
+			if err := r.Get(ctx, req.NamespacedName, hostproxy); err != nil {
+				log.Error(err, "Failed to re-fetch hostproxy")
+				return ctrl.Result{}, err
+			}
+
+			// The following implementation will update the status
+			r.recordEvent(hostproxy, "Warning", networkingv1.ReasonDeploymentFailed, err.Error())
+			meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+				Status: metav1.ConditionFalse, Reason: networkingv1.ReasonDeploymentFailed, ObservedGeneration: hostproxy.Generation,
+				Message: fmt.Sprintf("Failed to update the Deployment for the custom resource (%s)", hostproxy.Name)})
+
+			if err := r.Status().Update(ctx, hostproxy); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
+				log.Error(err, "Failed to update Hostproxy status")
+				return ctrl.Result{}, err
+			}
+
+			return ctrl.Result{}, err
+		}
+
+		// Now, that we update the Deployment we want to requeue the reconciliation
+		// so that we can ensure that we have the latest state of the resource before
+		// update. Also, it will help ensure the desired state on the cluster
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// The following implementation will update the status
+	if size == 0 {
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+			Status: metav1.ConditionFalse, Reason: networkingv1.ReasonScaledToZero, ObservedGeneration: hostproxy.Generation,
+			Message: fmt.Sprintf("Deployment for custom resource (%s) is scaled to zero", hostproxy.Name)})
+	} else {
+		meta.SetStatusCondition(
+			&hostproxy.Status.Conditions,
+			metav1.Condition{
+				Type:   typeAvailableHostproxy,
+				Status: metav1.ConditionTrue, Reason: networkingv1.ReasonDeploymentCreated, ObservedGeneration: hostproxy.Generation,
+				Message: fmt.Sprintf("Deployment for custom resource (%s) created successfully", hostproxy.Name),
+			},
+		)
+	}
+
+	// The state of the proxy pods is checked more often while they are rolling out
+	ready := found.Status.ReadyReplicas >= size
+	if ready {
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeProgressingHostproxy,
+			Status: metav1.ConditionFalse, Reason: networkingv1.ReasonPodsReady, ObservedGeneration: hostproxy.Generation,
+			Message: "All the proxy pods are ready"})
+	} else {
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeProgressingHostproxy,
+			Status: metav1.ConditionTrue, Reason: networkingv1.ReasonPodsNotReady, ObservedGeneration: hostproxy.Generation,
+			Message: "Waiting for the proxy pods to be ready"})
+	}
+	if hostproxy.Status.PortsEnv, err = portsEnvForHostproxy(hostproxy); err != nil {
+		log.Error(err, "Failed to render the port mapping")
+		return ctrl.Result{}, err
+	}
+	hostproxy.Status.ObservedGeneration = hostproxy.Generation
+	hostproxy.Status.ReconciledBy = r.Version
+
+	if err := r.Status().Update(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to update Hostproxy status")
+		return ctrl.Result{}, err
+	}
+
+	if !ready {
+		return ctrl.Result{RequeueAfter: notReadyRequeueInterval}, nil
+	}
+	return ctrl.Result{}, nil
 }
 
 // finalizeHostproxy will perform the required operations before delete the CR.
@@ -337,61 +1084,122 @@ func (r *HostproxyReconciler) doFinalizerOperationsForHostproxy(cr *networkingv1
 	// More info: https://kubernetes.io/docs/tasks/administer-cluster/use-cascading-deletion/
 
 	// The following implementation will raise an event
-	r.Recorder.Event(cr, "Warning", "Deleting",
+	r.recordEvent(cr, "Warning", "Deleting",
 		fmt.Sprintf("Custom Resource %s is being deleted from the namespace %s",
 			cr.Name,
 			cr.Namespace))
 }
 
-// deploymentForHostproxy returns a Hostproxy Deployment object
-func (r *HostproxyReconciler) deploymentForHostproxy(
-	hostproxy *networkingv1.Hostproxy) (*appsv1.Deployment, error) {
-	ls := labelsForHostproxy(hostproxy.Name)
-	replicas := int32(1)
+// cleanupHostState runs a Job removing the state written by the proxy on every node running a
+// proxy pod, and returns true once all of them are finished
+func (r *HostproxyReconciler) cleanupHostState(ctx context.Context, hostproxy *networkingv1.Hostproxy) (bool, error) {
+	log := log.FromContext(ctx)
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(hostproxy.Namespace),
+		client.MatchingLabels(podtemplate.SelectorLabels(hostproxy.Name))); err != nil {
+		return false, err
+	}
+	nodes := map[string]bool{}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" {
+			nodes[pod.Spec.NodeName] = true
+		}
+	}
+
+	done := true
+	for node := range nodes {
+		job, err := r.cleanupJobForHostproxy(hostproxy, node)
+		if err != nil {
+			return false, err
+		}
+
+		found := &batchv1.Job{}
+		err = r.Get(ctx, client.ObjectKeyFromObject(job), found)
+		if apierrors.IsNotFound(err) {
+			log.Info("Creating a cleanup Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name, "Node", node)
+			if err = r.Create(ctx, job); namespaceTerminating(err) {
+				// The cleanup can't run anymore, which mustn't keep the namespace from being deleted
+				r.recordEvent(hostproxy, "Warning", networkingv1.ReasonCleanupFailed,
+					fmt.Sprintf("The cleanup Job %s can't be created in the terminating namespace", job.Name))
+				continue
+			} else if err != nil {
+				return false, err
+			}
+			done = false
+			continue
+		} else if err != nil {
+			return false, err
+		}
+
+		switch {
+		case jobFinished(found, batchv1.JobComplete):
+		case jobFinished(found, batchv1.JobFailed):
+			// A failed cleanup doesn't block the deletion of the Hostproxy forever, neither does
+			// a cleanup stopped by its deadline
+			r.recordEvent(hostproxy, "Warning", networkingv1.ReasonCleanupFailed,
+				fmt.Sprintf("The cleanup Job %s failed on the node %s", found.Name, node))
+		default:
+			done = false
+		}
+	}
+	return done, nil
+}
 
-	// Get the Operand image
+// namespaceTerminating returns true when the given error is the API server refusing to create an
+// object in a terminating namespace
+func namespaceTerminating(err error) bool {
+	return apierrors.IsForbidden(err) && apierrors.HasStatusCause(err, corev1.NamespaceTerminatingCause)
+}
+
+// cleanupJobForHostproxy returns the Job removing the state written by the proxy on the given node
+func (r *HostproxyReconciler) cleanupJobForHostproxy(hostproxy *networkingv1.Hostproxy, node string) (*batchv1.Job, error) {
 	image, err := imageForHostproxy()
 	if err != nil {
 		return nil, err
 	}
 
-	dep := &appsv1.Deployment{
+	// The Job is named after a hash of the node, since the node names may be too long
+	nodeHash := fmt.Sprintf("%x", sha256.Sum256([]byte(node)))
+	privileged := true
+	backoffLimit := int32(3)
+	deadline := defaultCleanupDeadlineSeconds
+	if hostproxy.Spec.CleanupDeadlineSeconds != nil {
+		deadline = *hostproxy.Spec.CleanupDeadlineSeconds
+	}
+	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      hostproxy.Name,
+			Name:      hostproxy.Name + "-cleanup-" + nodeHash[:8],
 			Namespace: hostproxy.Namespace,
+			Labels:    labelsForHostproxy(hostproxy.Name),
 		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &replicas,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: ls,
-			},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
 			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: ls,
-				},
 				Spec: corev1.PodSpec{
-					SecurityContext: &corev1.PodSecurityContext{
-						SeccompProfile: &corev1.SeccompProfile{
-							Type: corev1.SeccompProfileTypeRuntimeDefault,
-						},
-					},
+					// The pod is bound to the node without going through the scheduler
+					NodeName:      node,
+					HostNetwork:   true,
+					RestartPolicy: corev1.RestartPolicyNever,
+					Tolerations:   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
 					Containers: []corev1.Container{{
+						Name:            "cleanup",
 						Image:           image,
-						Name:            "hostproxy",
 						ImagePullPolicy: corev1.PullIfNotPresent,
+						Command:         []string{"rm", "-rf", path.Join(hostStateDir, hostproxy.Namespace, hostproxy.Name)},
 						SecurityContext: &corev1.SecurityContext{
+							Privileged: &privileged,
 							Capabilities: &corev1.Capabilities{
-								Add: []corev1.Capability{
-									"NET_ADMIN",
-									"NET_RAW",
-								},
+								Add: []corev1.Capability{"NET_ADMIN"},
 							},
 						},
-						Env: []corev1.EnvVar{
-							{
-								Name:  "PORTS",
-								Value: fmt.Sprintf("%d:%d", hostproxy.Spec.ClusterPort, hostproxy.Spec.HostPort),
-							},
+						VolumeMounts: []corev1.VolumeMount{{Name: "state", MountPath: hostStateDir}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "state",
+						VolumeSource: corev1.VolumeSource{
+							HostPath: &corev1.HostPathVolumeSource{Path: hostStateDir},
 						},
 					}},
 				},
@@ -399,69 +1207,1736 @@ func (r *HostproxyReconciler) deploymentForHostproxy(
 		},
 	}
 
-	// Set the ownerRef for the Deployment
+	// Set the ownerRef for the Job
 	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
-	if err := ctrl.SetControllerReference(hostproxy, dep, r.Scheme); err != nil {
+	if err := ctrl.SetControllerReference(hostproxy, job, r.Scheme); err != nil {
 		return nil, err
 	}
-	return dep, nil
+	return job, nil
 }
 
-func (r *HostproxyReconciler) serviceForHostproxy(hostproxy *networkingv1.Hostproxy) (*corev1.Service, error) {
-	ls := labelsForHostproxy(hostproxy.Name)
-	svc := &corev1.Service{
-		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Service"},
+// jobFinished returns true if the Job reports the given finished condition
+func jobFinished(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileBackends reconciles the Deployments of the backends of a Hostproxy, between which its
+// replicas are split in proportion of their weights
+func (r *HostproxyReconciler) reconcileBackends(ctx context.Context,
+	hostproxy *networkingv1.Hostproxy) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	// Remove the Deployment of the single host
+	if err := r.deleteControlledObject(ctx, hostproxy, deploymentNameForHostproxy(hostproxy), &appsv1.Deployment{}); err != nil {
+		log.Error(err, "Failed to delete the Deployment")
+		return ctrl.Result{}, err
+	}
+
+	// The proxy pods may run with a ServiceAccount dedicated to the Hostproxy
+	if err := r.reconcileServiceAccount(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to reconcile the ServiceAccount")
+		return r.reportChildFailure(ctx, hostproxy, networkingv1.ReasonServiceAccountFailed, "ServiceAccount", err)
+	}
+
+	// The PodDisruptionBudget protects the proxy pods of all the backends
+	if err := r.reconcilePodDisruptionBudget(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to reconcile the PodDisruptionBudget")
+		return r.reportChildFailure(ctx, hostproxy, networkingv1.ReasonPodDisruptionBudgetFailed, "PodDisruptionBudget", err)
+	}
+
+	// The access to the proxy pods may be restricted to some sources by a NetworkPolicy
+	if err := r.reconcileNetworkPolicy(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to reconcile the NetworkPolicy")
+		return r.reportChildFailure(ctx, hostproxy, networkingv1.ReasonNetworkPolicyFailed, "NetworkPolicy", err)
+	}
+
+	replicas := replicasForBackends(hostproxy)
+	backends := make(map[string]bool, len(hostproxy.Spec.Backends))
+	var readyReplicas, size int32
+	for i, backend := range hostproxy.Spec.Backends {
+		backends[backendDeploymentName(hostproxy, backend)] = true
+		size += replicas[i]
+
+		desired, err := r.deploymentForBackend(hostproxy, backend, replicas[i])
+		if err != nil {
+			log.Error(err, "Failed to define new Deployment resource for the backend", "Backend", backend.Name)
+
+			// The following implementation will update the status
+			r.recordEvent(hostproxy, "Warning", networkingv1.ReasonDeploymentFailed, err.Error())
+			meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+				Status: metav1.ConditionFalse, Reason: networkingv1.ReasonDeploymentFailed, ObservedGeneration: hostproxy.Generation,
+				Message: fmt.Sprintf("Failed to create the Deployments for the custom resource (%s)", hostproxy.Name)})
+
+			if err := r.Status().Update(ctx, hostproxy); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
+				log.Error(err, "Failed to update Hostproxy status")
+				return ctrl.Result{}, err
+			}
+
+			return ctrl.Result{}, err
+		}
+
+		found := &appsv1.Deployment{}
+		err = r.Get(ctx, client.ObjectKeyFromObject(desired), found)
+		if err != nil && apierrors.IsNotFound(err) {
+			log.Info("Creating a new Deployment", "Deployment.Namespace", desired.Namespace, "Deployment.Name", desired.Name)
+			if err = r.Create(ctx, desired); err != nil {
+				log.Error(err, "Failed to create new Deployment", "Deployment.Namespace", desired.Namespace, "Deployment.Name", desired.Name)
+				return r.reportChildFailure(ctx, hostproxy, networkingv1.ReasonDeploymentFailed, "Deployments", err)
+			}
+			continue
+		} else if err != nil {
+			log.Error(err, "Failed to get Deployment", "Deployment.Namespace", desired.Namespace, "Deployment.Name", desired.Name)
+			return ctrl.Result{}, err
+		}
+
+		// Like the Deployment of the single host, the Deployment of a backend left by a former
+		// Hostproxy of the same name is recreated, and the one selecting other labels than the
+		// desired ones too, since its selector is immutable
+		if ownedByStaleHostproxy(found, hostproxy) || (metav1.IsControlledBy(found, hostproxy) &&
+			(!selectsHostproxy(found.Spec.Selector, hostproxy.Name) || found.Spec.Selector.MatchLabels[backendLabel] != backend.Name)) {
+			log.Info("Recreating the Deployment of the backend",
+				"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+			if err = r.Delete(ctx, found, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "Failed to delete Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+				return ctrl.Result{}, err
+			}
+
+			// The Deployment is created again by the next reconciliation
+			return ctrl.Result{Requeue: true}, nil
+		}
+
+		// A Deployment of the backend created before the Hostproxy is adopted
+		if err = r.adoptObject(ctx, hostproxy, found); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to adopt Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+			return ctrl.Result{}, err
+		}
+		readyReplicas += found.Status.ReadyReplicas
+
+		// Ensure the Deployment of the backend matches the spec and runs its share of the replicas
+		hash := desired.Annotations[specHashAnnotation]
+		if found.Annotations[specHashAnnotation] != hash || found.Spec.Replicas == nil ||
+			*found.Spec.Replicas != replicas[i] || proxyContainerDrifted(&found.Spec.Template, &desired.Spec.Template) {
+			syncDeployment(found, desired)
+			found.Spec.Replicas = desired.Spec.Replicas
+			metav1.SetMetaDataAnnotation(&found.ObjectMeta, specHashAnnotation, hash)
+
+			log.Info("Updating the Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+			if err = r.Update(ctx, found); err != nil {
+				if apierrors.IsConflict(err) {
+					return ctrl.Result{}, err
+				}
+				log.Error(err, "Failed to update Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+				return r.reportChildFailure(ctx, hostproxy, networkingv1.ReasonDeploymentFailed, "Deployments", err)
+			}
+		}
+	}
+
+	// Remove the Deployments of the backends which have been removed from the spec
+	if err := r.deleteBackendDeployments(ctx, hostproxy, backends); err != nil {
+		log.Error(err, "Failed to delete the Deployments of the backends")
+		return ctrl.Result{}, err
+	}
+
+	if size == 0 {
+		// Like a single host, backends scaled to zero are suspended: there is no proxy pod to check
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeSuspendedHostproxy,
+			Status: metav1.ConditionTrue, Reason: networkingv1.ReasonScaledToZero, ObservedGeneration: hostproxy.Generation,
+			Message: "The Hostproxy is suspended since it has no replicas"})
+		meta.RemoveStatusCondition(&hostproxy.Status.Conditions, typeDegradedHostproxy)
+	} else {
+		meta.RemoveStatusCondition(&hostproxy.Status.Conditions, typeSuspendedHostproxy)
+		if err := r.setDegradedCondition(ctx, hostproxy); err != nil {
+			log.Error(err, "Failed to list the proxy pods")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Report the child resources managed for this Hostproxy
+	hostproxy.Status.OwnedResources = ownedResourcesForHostproxy(hostproxy)
+
+	// Report the proxy image which has been resolved for this Hostproxy
+	if image, err := imageForHostproxy(); err == nil {
+		hostproxy.Status.Image = image
+		hostproxy.Status.ImageVersion = podtemplate.ImageVersion(image)
+	}
+
+	// The following implementation will update the status
+	meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+		Status: metav1.ConditionTrue, Reason: networkingv1.ReasonDeploymentCreated, ObservedGeneration: hostproxy.Generation,
+		Message: fmt.Sprintf("Deployments for custom resource (%s) created successfully", hostproxy.Name)})
+
+	// The state of the proxy pods is checked more often while they are rolling out
+	ready := readyReplicas >= size
+	if ready {
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeProgressingHostproxy,
+			Status: metav1.ConditionFalse, Reason: networkingv1.ReasonPodsReady, ObservedGeneration: hostproxy.Generation,
+			Message: "All the proxy pods are ready"})
+	} else {
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeProgressingHostproxy,
+			Status: metav1.ConditionTrue, Reason: networkingv1.ReasonPodsNotReady, ObservedGeneration: hostproxy.Generation,
+			Message: "Waiting for the proxy pods to be ready"})
+	}
+	ports, err := portsEnvForHostproxy(hostproxy)
+	if err != nil {
+		log.Error(err, "Failed to render the port mapping")
+		return ctrl.Result{}, err
+	}
+	hostproxy.Status.PortsEnv = ports
+	hostproxy.Status.ObservedGeneration = hostproxy.Generation
+	hostproxy.Status.ReconciledBy = r.Version
+
+	if err := r.Status().Update(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to update Hostproxy status")
+		return ctrl.Result{}, err
+	}
+
+	if !ready {
+		return ctrl.Result{RequeueAfter: notReadyRequeueInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// deleteBackendDeployments deletes the Deployments of the backends of a Hostproxy, except the
+// ones whose name is kept
+func (r *HostproxyReconciler) deleteBackendDeployments(ctx context.Context, hostproxy *networkingv1.Hostproxy,
+	keep map[string]bool) error {
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, client.InNamespace(hostproxy.Namespace), client.HasLabels{backendLabel}); err != nil {
+		return err
+	}
+	for i := range deployments.Items {
+		dep := &deployments.Items[i]
+		if keep[dep.Name] || !metav1.IsControlledBy(dep, hostproxy) {
+			continue
+		}
+		log.FromContext(ctx).Info("Deleting the Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+		if err := r.Delete(ctx, dep); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteCrossNamespaceServices deletes the Services managed by the Hostproxy in other namespaces than
+// its own, except the kept one. Their Endpoints are garbage collected with them.
+func (r *HostproxyReconciler) deleteCrossNamespaceServices(ctx context.Context, hostproxy *networkingv1.Hostproxy,
+	keep types.NamespacedName) error {
+	services := &corev1.ServiceList{}
+	if err := r.List(ctx, services, client.MatchingLabels{
+		ownerNamespaceLabel:          hostproxy.Namespace,
+		"app.kubernetes.io/instance": hostproxy.Name,
+	}); err != nil {
+		return err
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		if client.ObjectKeyFromObject(svc) == keep {
+			continue
+		}
+		log.FromContext(ctx).Info("Deleting the Service", "Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+		if err := r.Delete(ctx, svc); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcileEndpoints sets the proxy pods as the endpoints of a Service of another namespace than the
+// one of the Hostproxy, which can't select them. The Endpoints are controlled by the Service. Like
+// the endpoints controller does, the pods which aren't ready are only published when the Service
+// publishes the addresses which aren't ready.
+func (r *HostproxyReconciler) reconcileEndpoints(ctx context.Context, hostproxy *networkingv1.Hostproxy,
+	svc *corev1.Service) error {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(hostproxy.Namespace),
+		client.MatchingLabels(podtemplate.SelectorLabels(hostproxy.Name))); err != nil {
+		return err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+	subset := corev1.EndpointSubset{}
+	for _, port := range svc.Spec.Ports {
+		subset.Ports = append(subset.Ports, corev1.EndpointPort{Name: port.Name, Port: port.TargetPort.IntVal,
+			Protocol: port.Protocol})
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.PodIP == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+		nodeName := pod.Spec.NodeName
+		address := corev1.EndpointAddress{
+			IP:       pod.Status.PodIP,
+			NodeName: &nodeName,
+			TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name,
+				UID: pod.UID},
+		}
+		if ready := podCondition(pod, corev1.PodReady); svc.Spec.PublishNotReadyAddresses ||
+			(ready != nil && ready.Status == corev1.ConditionTrue) {
+			subset.Addresses = append(subset.Addresses, address)
+		} else {
+			subset.NotReadyAddresses = append(subset.NotReadyAddresses, address)
+		}
+	}
+
+	endpoints := &corev1.Endpoints{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      hostproxy.Name,
-			Namespace: hostproxy.Namespace,
-		},
-		Spec: corev1.ServiceSpec{
-			ClusterIP: "None",
-			Selector:  ls,
+			Name:      svc.Name,
+			Namespace: svc.Namespace,
+			Labels:    svc.Labels,
 		},
 	}
+	if len(subset.Addresses)+len(subset.NotReadyAddresses) > 0 {
+		endpoints.Subsets = []corev1.EndpointSubset{subset}
+	}
+	if err := ctrl.SetControllerReference(svc, endpoints, r.Scheme); err != nil {
+		return err
+	}
 
-	// Set the ownerRef for the Service
-	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
-	if err := ctrl.SetControllerReference(hostproxy, svc, r.Scheme); err != nil {
-		return nil, err
+	found := &corev1.Endpoints{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(endpoints), found)
+	if apierrors.IsNotFound(err) {
+		log.FromContext(ctx).Info("Creating the Endpoints", "Endpoints.Namespace", endpoints.Namespace,
+			"Endpoints.Name", endpoints.Name)
+		return r.Create(ctx, endpoints)
+	} else if err != nil {
+		return err
 	}
-	return svc, nil
+	if equality.Semantic.DeepEqual(found.Subsets, endpoints.Subsets) {
+		return nil
+	}
+	found.Subsets = endpoints.Subsets
+	return r.Update(ctx, found)
 }
 
-// labelsForHostproxy returns the labels for selecting the resources
-// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/common-labels/
-func labelsForHostproxy(name string) map[string]string {
-	var imageTag string
-	image, err := imageForHostproxy()
-	if err == nil {
-		imageTag = strings.Split(image, ":")[1]
+// deleteRenamedDeployments deletes the Deployments of the single host controlled by the Hostproxy
+// under another name than the current one
+func (r *HostproxyReconciler) deleteRenamedDeployments(ctx context.Context, hostproxy *networkingv1.Hostproxy) error {
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, client.InNamespace(hostproxy.Namespace)); err != nil {
+		return err
 	}
-	return map[string]string{"app.kubernetes.io/name": "Hostproxy",
-		"app.kubernetes.io/instance":   name,
-		"app.kubernetes.io/version":    imageTag,
-		"app.kubernetes.io/part-of":    "hostproxy",
-		"app.kubernetes.io/created-by": "controller-manager",
+	for i := range deployments.Items {
+		dep := &deployments.Items[i]
+		if _, ok := dep.Labels[backendLabel]; ok || dep.Name == deploymentNameForHostproxy(hostproxy) ||
+			!metav1.IsControlledBy(dep, hostproxy) {
+			continue
+		}
+		log.FromContext(ctx).Info("Deleting the Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+		if err := r.Delete(ctx, dep); client.IgnoreNotFound(err) != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-// imageForHostproxy gets the Operand image which is managed by this controller
-// from the HOSTPROXY_IMAGE environment variable defined in the config/manager/manager.yaml
-func imageForHostproxy() (string, error) {
-	var imageEnvVar = "HOSTPROXY_IMAGE"
-	image, found := os.LookupEnv(imageEnvVar)
-	if !found {
-		return "", fmt.Errorf("Unable to find %s environment variable with the image", imageEnvVar)
+// reconcileDaemonSetMode reconciles a Hostproxy in DaemonSet mode, where a proxy pod runs on
+// every node of the cluster. There is no Service in this mode.
+func (r *HostproxyReconciler) reconcileDaemonSetMode(ctx context.Context,
+	hostproxy *networkingv1.Hostproxy) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	// Remove the children of the Deployment mode
+	if err := r.deleteControlledObject(ctx, hostproxy, deploymentNameForHostproxy(hostproxy), &appsv1.Deployment{}); err != nil {
+		log.Error(err, "Failed to delete the children of the Deployment mode")
+		return ctrl.Result{}, err
 	}
-	return image, nil
+	if err := r.deleteControlledObject(ctx, hostproxy, serviceNameForHostproxy(hostproxy), &corev1.Service{}); err != nil {
+		log.Error(err, "Failed to delete the children of the Deployment mode")
+		return ctrl.Result{}, err
+	}
+	if err := r.deleteCrossNamespaceServices(ctx, hostproxy, types.NamespacedName{}); err != nil {
+		log.Error(err, "Failed to delete the children of the Deployment mode")
+		return ctrl.Result{}, err
+	}
+	if err := r.deleteBackendDeployments(ctx, hostproxy, nil); err != nil {
+		log.Error(err, "Failed to delete the Deployments of the backends")
+		return ctrl.Result{}, err
+	}
+
+	// The proxy pods may run with a ServiceAccount dedicated to the Hostproxy
+	if err := r.reconcileServiceAccount(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to reconcile the ServiceAccount")
+		return r.reportChildFailure(ctx, hostproxy, networkingv1.ReasonServiceAccountFailed, "ServiceAccount", err)
+	}
+
+	// Remove the PodDisruptionBudget left by the Deployment mode
+	if err := r.reconcilePodDisruptionBudget(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to reconcile the PodDisruptionBudget")
+		return r.reportChildFailure(ctx, hostproxy, networkingv1.ReasonPodDisruptionBudgetFailed, "PodDisruptionBudget", err)
+	}
+
+	// The access to the proxy pods may be restricted to some sources by a NetworkPolicy
+	if err := r.reconcileNetworkPolicy(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to reconcile the NetworkPolicy")
+		return r.reportChildFailure(ctx, hostproxy, networkingv1.ReasonNetworkPolicyFailed, "NetworkPolicy", err)
+	}
+
+	desired, err := r.daemonSetForHostproxy(hostproxy)
+	if err != nil {
+		log.Error(err, "Failed to define new DaemonSet resource for Hostproxy")
+
+		// The following implementation will update the status
+		r.recordEvent(hostproxy, "Warning", networkingv1.ReasonDaemonSetFailed, err.Error())
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+			Status: metav1.ConditionFalse, Reason: networkingv1.ReasonDaemonSetFailed, ObservedGeneration: hostproxy.Generation,
+			Message: fmt.Sprintf("Failed to create DaemonSet for the custom resource (%s)", hostproxy.Name)})
+
+		if err := r.Status().Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update Hostproxy status")
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	// Check if the DaemonSet already exists, if not create a new one
+	found := &appsv1.DaemonSet{}
+	err = r.Get(ctx, types.NamespacedName{Name: hostproxy.Name, Namespace: hostproxy.Namespace}, found)
+	if err != nil && apierrors.IsNotFound(err) {
+		log.Info("Creating a new DaemonSet", "DaemonSet.Namespace", desired.Namespace, "DaemonSet.Name", desired.Name)
+		if err = r.Create(ctx, desired); err != nil {
+			log.Error(err, "Failed to create new DaemonSet", "DaemonSet.Namespace", desired.Namespace, "DaemonSet.Name", desired.Name)
+			return ctrl.Result{}, err
+		}
+
+		addRecentEvent(hostproxy, "Normal", networkingv1.ReasonDaemonSetCreated,
+			fmt.Sprintf("Created the DaemonSet %s", desired.Name))
+		if err := r.Status().Update(ctx, hostproxy); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update Hostproxy status")
+			return ctrl.Result{}, err
+		}
+
+		// DaemonSet created successfully
+		// We will requeue the reconciliation so that we can ensure the state
+		// and move forward for the next operations
+		return ctrl.Result{RequeueAfter: r.requeueInterval()}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get DaemonSet", "DaemonSet.Namespace", hostproxy.Namespace, "DaemonSet.Name", hostproxy.Name)
+		// Let's return the error for the reconciliation be re-trigged again
+		return ctrl.Result{}, err
+	}
+
+	if ownedByStaleHostproxy(found, hostproxy) {
+		log.Info("Recreating the DaemonSet of a former Hostproxy",
+			"DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", found.Name)
+		if err = r.Delete(ctx, found, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to delete DaemonSet", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", found.Name)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if err = r.adoptObject(ctx, hostproxy, found); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to adopt DaemonSet", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", found.Name)
+		return ctrl.Result{}, err
+	}
+
+	// Ensure the pod template of the DaemonSet matches the spec, unless the hash of the
+	// desired spec matches the one recorded on the DaemonSet
+	if hash := desired.Annotations[specHashAnnotation]; found.Annotations[specHashAnnotation] != hash ||
+		proxyContainerDrifted(&found.Spec.Template, &desired.Spec.Template) {
+		mergeLabels(&found.ObjectMeta, desired.Labels)
+		found.Spec.MinReadySeconds = desired.Spec.MinReadySeconds
+		syncPodTemplate(&found.Spec.Template, &desired.Spec.Template)
+		metav1.SetMetaDataAnnotation(&found.ObjectMeta, specHashAnnotation, hash)
+
+		log.Info("Updating the DaemonSet", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", found.Name)
+		if err = r.Update(ctx, found); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "Failed to update DaemonSet", "DaemonSet.Namespace", found.Namespace, "DaemonSet.Name", found.Name)
+			return ctrl.Result{}, err
+		}
+
+		// Now, that we update the DaemonSet we want to requeue the reconciliation
+		// so that we can ensure that we have the latest state of the resource before
+		// update. Also, it will help ensure the desired state on the cluster
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	meta.RemoveStatusCondition(&hostproxy.Status.Conditions, typeSuspendedHostproxy)
+	if err = r.setDegradedCondition(ctx, hostproxy); err != nil {
+		log.Error(err, "Failed to list the proxy pods")
+		return ctrl.Result{}, err
+	}
+
+	// Report the child resources managed for this Hostproxy
+	hostproxy.Status.OwnedResources = ownedResourcesForHostproxy(hostproxy)
+
+	// Report the proxy image which has been resolved for this Hostproxy
+	if image, err := imageForHostproxy(); err == nil {
+		hostproxy.Status.Image = image
+		hostproxy.Status.ImageVersion = podtemplate.ImageVersion(image)
+	}
+
+	// The following implementation will update the status
+	meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+		Status: metav1.ConditionTrue, Reason: networkingv1.ReasonDaemonSetCreated, ObservedGeneration: hostproxy.Generation,
+		Message: fmt.Sprintf("DaemonSet for custom resource (%s) created successfully", hostproxy.Name)})
+	if hostproxy.Status.PortsEnv, err = portsEnvForHostproxy(hostproxy); err != nil {
+		log.Error(err, "Failed to render the port mapping")
+		return ctrl.Result{}, err
+	}
+	hostproxy.Status.ObservedGeneration = hostproxy.Generation
+	hostproxy.Status.ReconciledBy = r.Version
+
+	if err := r.Status().Update(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to update Hostproxy status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// reportChildFailure reports the failure to reconcile a child of the Hostproxy with an event and
+// its conditions, and returns the error so that the reconciliation is retried
+func (r *HostproxyReconciler) reportChildFailure(ctx context.Context, hostproxy *networkingv1.Hostproxy,
+	reason, kind string, err error) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	// The following implementation will update the status
+	r.recordEvent(hostproxy, "Warning", reason, err.Error())
+	meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+		Status: metav1.ConditionFalse, Reason: reason, ObservedGeneration: hostproxy.Generation,
+		Message: fmt.Sprintf("Failed to reconcile the %s for the custom resource (%s)", kind, hostproxy.Name)})
+	if err := r.setDegradedCondition(ctx, hostproxy); err != nil {
+		log.Error(err, "Failed to list the proxy pods")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.Status().Update(ctx, hostproxy); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "Failed to update Hostproxy status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, err
+}
+
+// recordEvent emits an event on the Hostproxy and keeps it in the recent events of its status,
+// which are saved with the next update of the status
+func (r *HostproxyReconciler) recordEvent(hostproxy *networkingv1.Hostproxy, eventType, reason, message string) {
+	r.Recorder.Event(hostproxy, eventType, reason, message)
+	addRecentEvent(hostproxy, eventType, reason, message)
+}
+
+// addRecentEvent appends an event to the recent events of the Hostproxy, dropping the oldest ones
+// beyond maxRecentEvents. An event repeating the last one only refreshes its time, so that a
+// failure retried with backoff doesn't push the other events out.
+func addRecentEvent(hostproxy *networkingv1.Hostproxy, eventType, reason, message string) {
+	events := hostproxy.Status.RecentEvents
+	if last := len(events) - 1; last >= 0 && events[last].Type == eventType &&
+		events[last].Reason == reason && events[last].Message == message {
+		events[last].Time = metav1.Now()
+		return
+	}
+
+	events = append(events, networkingv1.EventEntry{Time: metav1.Now(), Type: eventType, Reason: reason, Message: message})
+	if len(events) > maxRecentEvents {
+		events = events[len(events)-maxRecentEvents:]
+	}
+	hostproxy.Status.RecentEvents = events
+}
+
+// setDegradedCondition sets the Degraded condition of the Hostproxy from the state of its proxy pods
+func (r *HostproxyReconciler) setDegradedCondition(ctx context.Context, hostproxy *networkingv1.Hostproxy) error {
+	// The events are only recorded when the conditions change, since they are checked on every reconciliation
+	previousReason := degradedReason(hostproxy)
+	previousConflict := meta.FindStatusCondition(hostproxy.Status.Conditions, typeSelectorConflictHostproxy)
+
+	// Surface the problems of the proxy pods which prevent the proxy from working
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(hostproxy.Namespace),
+		client.MatchingLabels(podtemplate.SelectorLabels(hostproxy.Name))); err != nil {
+		return err
+	}
+
+	// The pods selected by the labels of the Hostproxy which it doesn't run would receive its traffic
+	if foreign := foreignProxyPods(hostproxy, pods.Items); len(foreign) > 0 {
+		message := fmt.Sprintf("The selector of the proxy pods matches foreign pods: %s", strings.Join(foreign, ", "))
+		if previousConflict == nil || previousConflict.Message != message {
+			r.recordEvent(hostproxy, "Warning", networkingv1.ReasonForeignPods, message)
+		}
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeSelectorConflictHostproxy,
+			Status: metav1.ConditionTrue, Reason: networkingv1.ReasonForeignPods, ObservedGeneration: hostproxy.Generation,
+			Message: message})
+	} else {
+		meta.RemoveStatusCondition(&hostproxy.Status.Conditions, typeSelectorConflictHostproxy)
+	}
+
+	// A proxy which keeps restarting is degraded even if its pods look fine in between
+	hostproxy.Status.RestartCount = restartCount(pods.Items)
+	reason, message, degraded := degradedProxyPods(pods.Items)
+	if threshold := restartThresholdForHostproxy(hostproxy); !degraded && hostproxy.Status.RestartCount > threshold {
+		reason, message, degraded = networkingv1.ReasonCrashLooping,
+			fmt.Sprintf("The containers of the proxy pods have restarted %d times", hostproxy.Status.RestartCount), true
+	}
+
+	// The proxy pods can't start while the ConfigMap or the Secret mounted in them is missing,
+	// which leaves its checksum empty
+	if ref := hostproxy.Spec.ConfigMapRef; ref != nil && hostproxy.Status.ConfigChecksum == "" {
+		reason, message, degraded = networkingv1.ReasonConfigMapNotFound,
+			fmt.Sprintf("The ConfigMap %s configuring the proxy doesn't exist", ref.Name), true
+	}
+	if ref := hostproxy.Spec.SecretRef; ref != nil && hostproxy.Status.CredentialsChecksum == "" {
+		reason, message, degraded = networkingv1.ReasonSecretNotFound,
+			fmt.Sprintf("The Secret %s holding the credentials of the proxy doesn't exist", ref.Name), true
+	}
+	if degraded {
+		if previousReason != reason {
+			r.recordEvent(hostproxy, "Warning", reason, message)
+		}
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeDegradedHostproxy,
+			Status: metav1.ConditionTrue, Reason: reason, ObservedGeneration: hostproxy.Generation,
+			Message: message})
+	} else {
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeDegradedHostproxy,
+			Status: metav1.ConditionFalse, Reason: networkingv1.ReasonHealthy, ObservedGeneration: hostproxy.Generation,
+			Message: "The proxy pods are healthy"})
+	}
+	return nil
+}
+
+// degradedReason returns the reason of the Degraded condition of the Hostproxy, or an empty string
+// when it isn't degraded
+func degradedReason(hostproxy *networkingv1.Hostproxy) string {
+	condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeDegradedHostproxy)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		return ""
+	}
+	return condition.Reason
+}
+
+// drainProxyPods annotates count proxy pods to be drained, and returns true once all of them
+// report that they are drained. The drained pods are given the lowest deletion cost, so that
+// they are the ones removed when the Deployment is scaled down.
+func (r *HostproxyReconciler) drainProxyPods(ctx context.Context, hostproxy *networkingv1.Hostproxy,
+	count int) (bool, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(hostproxy.Namespace),
+		client.MatchingLabels(podtemplate.SelectorLabels(hostproxy.Name))); err != nil {
+		return false, err
+	}
+
+	// The pods already draining are kept, the others being picked by name
+	candidates := make([]*corev1.Pod, 0, len(pods.Items))
+	for i := range pods.Items {
+		if pods.Items[i].DeletionTimestamp == nil {
+			candidates = append(candidates, &pods.Items[i])
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		iDraining := candidates[i].Annotations[networkingv1.DrainAnnotation] == "true"
+		jDraining := candidates[j].Annotations[networkingv1.DrainAnnotation] == "true"
+		if iDraining != jDraining {
+			return iDraining
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	if count > len(candidates) {
+		count = len(candidates)
+	}
+
+	drained := true
+	for _, pod := range candidates[:count] {
+		if pod.Annotations[networkingv1.DrainAnnotation] != "true" {
+			metav1.SetMetaDataAnnotation(&pod.ObjectMeta, networkingv1.DrainAnnotation, "true")
+			metav1.SetMetaDataAnnotation(&pod.ObjectMeta, podDeletionCostAnnotation, strconv.Itoa(math.MinInt32))
+			if err := r.Update(ctx, pod); err != nil {
+				return false, err
+			}
+		}
+
+		condition := podCondition(pod, networkingv1.DrainedPodCondition)
+		drained = drained && condition != nil && condition.Status == corev1.ConditionTrue
+	}
+	return drained, nil
+}
+
+// podCondition returns the condition of the given type of a pod, or nil if it isn't reported
+func podCondition(pod *corev1.Pod, conditionType corev1.PodConditionType) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == conditionType {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// deleteControlledObject deletes the object of the given name, of the kind of obj, if it is
+// controlled by the Hostproxy
+func (r *HostproxyReconciler) deleteControlledObject(ctx context.Context, hostproxy *networkingv1.Hostproxy,
+	name string, obj client.Object) error {
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: hostproxy.Namespace}, obj)
+	if err != nil || !metav1.IsControlledBy(obj, hostproxy) {
+		return client.IgnoreNotFound(err)
+	}
+
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("Deleting the "+gvk.Kind, gvk.Kind+".Namespace", obj.GetNamespace(), gvk.Kind+".Name", obj.GetName())
+	return client.IgnoreNotFound(r.Delete(ctx, obj))
+}
+
+// adoptObject sets the Hostproxy as the controller of an object which has no controller yet.
+// The objects controlled by another owner are left untouched.
+func (r *HostproxyReconciler) adoptObject(ctx context.Context, hostproxy *networkingv1.Hostproxy, obj client.Object) error {
+	if metav1.GetControllerOf(obj) != nil {
+		return nil
+	}
+	if err := ctrl.SetControllerReference(hostproxy, obj, r.Scheme); err != nil {
+		return err
+	}
+
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("Adopting the "+gvk.Kind, gvk.Kind+".Namespace", obj.GetNamespace(), gvk.Kind+".Name", obj.GetName())
+	return r.Update(ctx, obj)
+}
+
+// ownedByStaleHostproxy returns whether an object is controlled by a former Hostproxy which had
+// the same name as the given one
+func ownedByStaleHostproxy(obj metav1.Object, hostproxy *networkingv1.Hostproxy) bool {
+	owner := metav1.GetControllerOf(obj)
+	return owner != nil && owner.APIVersion == networkingv1.GroupVersion.String() &&
+		owner.Kind == "Hostproxy" && owner.Name == hostproxy.Name && owner.UID != hostproxy.UID
+}
+
+// deploymentForHostproxy returns a Hostproxy Deployment object
+func (r *HostproxyReconciler) deploymentForHostproxy(
+	hostproxy *networkingv1.Hostproxy) (*appsv1.Deployment, error) {
+	replicas := podtemplate.Replicas(hostproxy)
+
+	template, err := podTemplateForHostproxy(hostproxy)
+	if err != nil {
+		return nil, err
+	}
+
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploymentNameForHostproxy(hostproxy),
+			Namespace: hostproxy.Namespace,
+			Labels:    labelsForHostproxy(hostproxy.Name),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas:                &replicas,
+			MinReadySeconds:         hostproxy.Spec.MinReadySeconds,
+			ProgressDeadlineSeconds: hostproxy.Spec.ProgressDeadlineSeconds,
+			Paused:                  hostproxy.Annotations[networkingv1.DeploymentPausedAnnotation] == "true",
+			Selector: &metav1.LabelSelector{
+				MatchLabels: podtemplate.SelectorLabels(hostproxy.Name),
+			},
+			Template: template,
+		},
+	}
+
+	// Replace the proxy pods with the strategy of the spec, the default one of the Deployment otherwise
+	if hostproxy.Spec.UpdateStrategy != nil {
+		dep.Spec.Strategy = *hostproxy.Spec.UpdateStrategy.DeepCopy()
+	}
+	if hostproxy.Spec.MaxSurge != nil || hostproxy.Spec.MaxUnavailable != nil {
+		dep.Spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+		if dep.Spec.Strategy.RollingUpdate == nil {
+			dep.Spec.Strategy.RollingUpdate = &appsv1.RollingUpdateDeployment{}
+		}
+		if hostproxy.Spec.MaxSurge != nil {
+			dep.Spec.Strategy.RollingUpdate.MaxSurge = hostproxy.Spec.MaxSurge
+		}
+		if hostproxy.Spec.MaxUnavailable != nil {
+			dep.Spec.Strategy.RollingUpdate.MaxUnavailable = hostproxy.Spec.MaxUnavailable
+		}
+	}
+
+	if err := setDeploymentSpecHash(dep); err != nil {
+		return nil, err
+	}
+
+	// Set the ownerRef for the Deployment. Like for the other children, it blocks the owner deletion,
+	// so that a foreground deletion of the Hostproxy waits for the Deployment to be removed.
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(hostproxy, dep, r.Scheme); err != nil {
+		return nil, err
+	}
+	return dep, nil
+}
+
+// deploymentForBackend returns the Deployment of one of the backends of a Hostproxy, running
+// the given share of its replicas
+func (r *HostproxyReconciler) deploymentForBackend(hostproxy *networkingv1.Hostproxy,
+	backend networkingv1.Backend, replicas int32) (*appsv1.Deployment, error) {
+	// The Deployment of a backend is the one of a Hostproxy proxying the host of the backend
+	backendProxy := hostproxyForBackend(hostproxy, backend)
+	backendProxy.Spec.Replicas = &replicas
+	dep, err := r.deploymentForHostproxy(backendProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pods of the backends are told apart by their Deployments, while the Service selects
+	// all of them with the labels of the Hostproxy
+	dep.Name = backendDeploymentName(hostproxy, backend)
+	for _, labels := range []map[string]string{dep.Labels, dep.Spec.Selector.MatchLabels, dep.Spec.Template.Labels} {
+		labels[backendLabel] = backend.Name
+	}
+	if err := setDeploymentSpecHash(dep); err != nil {
+		return nil, err
+	}
+	return dep, nil
+}
+
+// hostproxyForBackend returns a copy of the Hostproxy proxying the host of one of its backends
+func hostproxyForBackend(hostproxy *networkingv1.Hostproxy, backend networkingv1.Backend) *networkingv1.Hostproxy {
+	backendProxy := hostproxy.DeepCopy()
+	backendProxy.Spec.HostPort = backend.HostPort
+	backendProxy.Spec.HostAddress = backend.HostAddress
+	return backendProxy
+}
+
+// setDeploymentSpecHash records the hash of the spec of a desired Deployment in its annotations
+func setDeploymentSpecHash(dep *appsv1.Deployment) error {
+	// The replicas are left out of the hash since they are reconciled on their own
+	spec := dep.Spec.DeepCopy()
+	spec.Replicas = nil
+	hash, err := specHash(spec)
+	if err != nil {
+		return err
+	}
+	metav1.SetMetaDataAnnotation(&dep.ObjectMeta, specHashAnnotation, hash)
+	return nil
+}
+
+// daemonSetForHostproxy returns the Hostproxy DaemonSet object of the DaemonSet mode
+func (r *HostproxyReconciler) daemonSetForHostproxy(
+	hostproxy *networkingv1.Hostproxy) (*appsv1.DaemonSet, error) {
+	template, err := podTemplateForHostproxy(hostproxy)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      hostproxy.Name,
+			Namespace: hostproxy.Namespace,
+			Labels:    labelsForHostproxy(hostproxy.Name),
+		},
+		Spec: appsv1.DaemonSetSpec{
+			MinReadySeconds: hostproxy.Spec.MinReadySeconds,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: podtemplate.SelectorLabels(hostproxy.Name),
+			},
+			Template: template,
+		},
+	}
+
+	hash, err := specHash(ds.Spec)
+	if err != nil {
+		return nil, err
+	}
+	metav1.SetMetaDataAnnotation(&ds.ObjectMeta, specHashAnnotation, hash)
+
+	// Set the ownerRef for the DaemonSet
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(hostproxy, ds, r.Scheme); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// podTemplateForHostproxy returns the template of the proxy pods running the Operand image
+func podTemplateForHostproxy(hostproxy *networkingv1.Hostproxy) (corev1.PodTemplateSpec, error) {
+	image, err := imageForHostproxy()
+	if err != nil {
+		return corev1.PodTemplateSpec{}, err
+	}
+	return podtemplate.ForHostproxy(hostproxy, image)
+}
+
+// specHash returns the hash of the spec of a workload, which is recorded on the workload
+// to detect the changes of the spec
+func specHash(spec interface{}) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// syncDeployment copies the fields managed by the controller from the desired Deployment
+// to the existing one.
+func syncDeployment(found, desired *appsv1.Deployment) {
+	mergeLabels(&found.ObjectMeta, desired.Labels)
+	if desired.Spec.Strategy.Type != "" {
+		found.Spec.Strategy = desired.Spec.Strategy
+	}
+	found.Spec.MinReadySeconds = desired.Spec.MinReadySeconds
+	found.Spec.ProgressDeadlineSeconds = desired.Spec.ProgressDeadlineSeconds
+	found.Spec.Paused = desired.Spec.Paused
+	syncPodTemplate(&found.Spec.Template, &desired.Spec.Template)
+}
+
+// proxyContainerDrifted returns whether the image or the capabilities of the proxy container differ
+// from the desired ones, which the hash of the spec doesn't reveal when they have been changed by hand
+func proxyContainerDrifted(found, desired *corev1.PodTemplateSpec) bool {
+	desiredContainer := &desired.Spec.Containers[0]
+	foundContainer := containerByName(found.Spec.Containers, desiredContainer.Name)
+	if foundContainer == nil {
+		return false
+	}
+	return foundContainer.Image != desiredContainer.Image ||
+		!equality.Semantic.DeepEqual(capabilitiesOf(foundContainer), capabilitiesOf(desiredContainer))
+}
+
+// capabilitiesOf returns the capabilities of a container, if any
+func capabilitiesOf(container *corev1.Container) *corev1.Capabilities {
+	if container.SecurityContext == nil {
+		return nil
+	}
+	return container.SecurityContext.Capabilities
+}
+
+// mergeLabels sets the labels managed by the controller on an existing object, keeping
+// the labels added by the users or by other controllers
+func mergeLabels(found *metav1.ObjectMeta, labels map[string]string) {
+	for key, value := range labels {
+		metav1.SetMetaDataLabel(found, key, value)
+	}
+}
+
+// syncPodTemplate copies the fields managed by the controller from the desired pod template
+// to the existing one.
+func syncPodTemplate(found, desired *corev1.PodTemplateSpec) {
+	// The proxy container is located by name, since other containers may have been
+	// added to the pod template, for instance by a service mesh
+	desiredContainer := &desired.Spec.Containers[0]
+	if foundContainer := containerByName(found.Spec.Containers, desiredContainer.Name); foundContainer != nil {
+		foundContainer.Image = desiredContainer.Image
+		foundContainer.SecurityContext = desiredContainer.SecurityContext
+		foundContainer.Env = desiredContainer.Env
+		foundContainer.VolumeMounts = desiredContainer.VolumeMounts
+		foundContainer.ReadinessProbe = desiredContainer.ReadinessProbe
+		foundContainer.LivenessProbe = desiredContainer.LivenessProbe
+		foundContainer.StartupProbe = desiredContainer.StartupProbe
+		foundContainer.Ports = desiredContainer.Ports
+		foundContainer.TerminationMessagePolicy = desiredContainer.TerminationMessagePolicy
+	} else {
+		// The proxy container has been renamed
+		found.Spec.Containers = desired.Spec.Containers
+	}
+
+	// The sidecars removed from the spec are removed from the pod template, the other ones
+	// being replaced by their desired version
+	for _, name := range strings.Split(found.Annotations[podtemplate.SidecarsAnnotation], ",") {
+		if name != "" && containerByName(desired.Spec.Containers, name) == nil {
+			found.Spec.Containers = removeContainer(found.Spec.Containers, name)
+		}
+	}
+	for _, sidecar := range desired.Spec.Containers[1:] {
+		if foundSidecar := containerByName(found.Spec.Containers, sidecar.Name); foundSidecar != nil {
+			*foundSidecar = sidecar
+		} else {
+			found.Spec.Containers = append(found.Spec.Containers, sidecar)
+		}
+	}
+
+	// Only the AppArmor annotation of the proxy container, the Prometheus annotations, the list
+	// of the sidecars and the checksums of the mounted data are managed, the other annotations of
+	// the pod template being left to the other controllers
+	for _, annotation := range []string{
+		corev1.AppArmorBetaContainerAnnotationKeyPrefix + desiredContainer.Name,
+		podtemplate.PrometheusScrapeAnnotation,
+		podtemplate.PrometheusPortAnnotation,
+		podtemplate.SidecarsAnnotation,
+		podtemplate.ConfigChecksumAnnotation,
+		podtemplate.CredentialsChecksumAnnotation,
+	} {
+		if value, ok := desired.Annotations[annotation]; ok {
+			metav1.SetMetaDataAnnotation(&found.ObjectMeta, annotation, value)
+		} else {
+			delete(found.Annotations, annotation)
+		}
+	}
+
+	foundPod := &found.Spec
+	desiredPod := &desired.Spec
+	foundPod.SecurityContext = desiredPod.SecurityContext
+	if desiredPod.ServiceAccountName != "" {
+		foundPod.ServiceAccountName = desiredPod.ServiceAccountName
+	}
+	foundPod.AutomountServiceAccountToken = desiredPod.AutomountServiceAccountToken
+	foundPod.TopologySpreadConstraints = desiredPod.TopologySpreadConstraints
+	foundPod.Affinity = desiredPod.Affinity
+	foundPod.NodeSelector = desiredPod.NodeSelector
+	foundPod.ReadinessGates = desiredPod.ReadinessGates
+	foundPod.Volumes = desiredPod.Volumes
+	if desiredPod.DNSPolicy != "" {
+		foundPod.DNSPolicy = desiredPod.DNSPolicy
+	} else {
+		foundPod.DNSPolicy = corev1.DNSClusterFirst
+	}
+	foundPod.DNSConfig = desiredPod.DNSConfig
+}
+
+func (r *HostproxyReconciler) serviceForHostproxy(hostproxy *networkingv1.Hostproxy) (*corev1.Service, error) {
+	svc := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceNameForHostproxy(hostproxy),
+			Namespace: serviceNamespaceForHostproxy(hostproxy),
+			Labels:    labelsForHostproxy(hostproxy.Name),
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: "None",
+			Selector:  podtemplate.SelectorLabels(hostproxy.Name),
+			Ports: []corev1.ServicePort{{
+				Name:       "proxy",
+				Port:       hostproxy.Spec.ClusterPort,
+				TargetPort: intstr.FromInt32(hostproxy.Spec.ClusterPort),
+				Protocol:   corev1.ProtocolTCP,
+			}},
+			IPFamilyPolicy:           hostproxy.Spec.IPFamilyPolicy,
+			PublishNotReadyAddresses: hostproxy.Spec.PublishNotReadyAddresses,
+		},
+	}
+
+	// The Service of a raw mode proxy only resolves the addresses of the proxy pods
+	if hostproxy.Spec.RawMode {
+		svc.Spec.Ports = nil
+	}
+
+	// Only a ClusterIP Service is headless, the other types being reached through their cluster IP
+	if serviceType := hostproxy.Spec.ServiceType; serviceType == corev1.ServiceTypeNodePort ||
+		serviceType == corev1.ServiceTypeLoadBalancer {
+		svc.Spec.Type = serviceType
+		svc.Spec.ClusterIP = ""
+		svc.Spec.ExternalTrafficPolicy = hostproxy.Spec.ExternalTrafficPolicy
+	}
+
+	// Cross-namespace owner references are not allowed, so the Service of another namespace is
+	// labelled with the namespace of its Hostproxy instead. Its selector would only match pods of
+	// its own namespace.
+	if svc.Namespace != hostproxy.Namespace {
+		svc.Labels = labelsForHostproxy(hostproxy.Name)
+		svc.Labels[ownerNamespaceLabel] = hostproxy.Namespace
+		svc.Spec.Selector = nil
+		return svc, nil
+	}
+
+	// Set the ownerRef for the Service
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(hostproxy, svc, r.Scheme); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// portsEnvForHostproxy returns the port mapping passed to the proxies of the Hostproxy. The
+// mappings of its backends, each passed to the proxies of its own Deployment, are joined with commas.
+func portsEnvForHostproxy(hostproxy *networkingv1.Hostproxy) (string, error) {
+	if hostproxy.Spec.Mode == networkingv1.DaemonSetMode || len(hostproxy.Spec.Backends) == 0 {
+		return podtemplate.Ports(hostproxy)
+	}
+	mappings := make([]string, 0, len(hostproxy.Spec.Backends))
+	for _, backend := range hostproxy.Spec.Backends {
+		ports, err := podtemplate.Ports(hostproxyForBackend(hostproxy, backend))
+		if err != nil {
+			return "", err
+		}
+		mappings = append(mappings, ports)
+	}
+	return strings.Join(mappings, ","), nil
+}
+
+// allocateHostPort returns the first port of the configured range which is used by none
+// of the Hostproxies of the cluster, since they all share the ports of the nodes
+func (r *HostproxyReconciler) allocateHostPort(ctx context.Context) (int32, error) {
+	hostproxies := &networkingv1.HostproxyList{}
+	if err := r.List(ctx, hostproxies); err != nil {
+		return 0, err
+	}
+
+	// The ports of the backends are bound on the nodes as well
+	used := map[int32]bool{}
+	for i := range hostproxies.Items {
+		used[podtemplate.HostPort(&hostproxies.Items[i])] = true
+		for _, backend := range hostproxies.Items[i].Spec.Backends {
+			used[backend.HostPort] = true
+		}
+	}
+
+	start, end := r.HostPortRangeStart, r.HostPortRangeEnd
+	if start == 0 && end == 0 {
+		start, end = defaultHostPortRangeStart, defaultHostPortRangeEnd
+	}
+	for port := start; port <= end; port++ {
+		if !used[port] {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free host port in the range %d-%d", start, end)
+}
+
+// reconcilePodDisruptionBudget creates the PodDisruptionBudget of the Hostproxy when it has several
+// replicas, and deletes the one owned by the Hostproxy when it isn't needed anymore.
+func (r *HostproxyReconciler) reconcilePodDisruptionBudget(ctx context.Context, hostproxy *networkingv1.Hostproxy) error {
+	log := log.FromContext(ctx)
+
+	wanted := wantsPodDisruptionBudget(hostproxy)
+
+	found := &policyv1.PodDisruptionBudget{}
+	err := r.Get(ctx, types.NamespacedName{Name: hostproxy.Name, Namespace: hostproxy.Namespace}, found)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	// A PodDisruptionBudget left by a former Hostproxy of the same name is recreated
+	if exists && ownedByStaleHostproxy(found, hostproxy) {
+		log.Info("Recreating the PodDisruptionBudget of a former Hostproxy",
+			"PodDisruptionBudget.Namespace", found.Namespace, "PodDisruptionBudget.Name", found.Name)
+		if err := r.Delete(ctx, found); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		exists = false
+	}
+
+	switch {
+	case wanted && exists:
+		if err := r.adoptObject(ctx, hostproxy, found); err != nil {
+			return err
+		}
+
+		// The selector of a PodDisruptionBudget is mutable, so it is repaired in place
+		if metav1.IsControlledBy(found, hostproxy) && !selectsHostproxy(found.Spec.Selector, hostproxy.Name) {
+			log.Info("Restoring the selector of the PodDisruptionBudget",
+				"PodDisruptionBudget.Namespace", found.Namespace, "PodDisruptionBudget.Name", found.Name)
+			found.Spec.Selector = &metav1.LabelSelector{MatchLabels: podtemplate.SelectorLabels(hostproxy.Name)}
+			return r.Update(ctx, found)
+		}
+	case wanted && !exists:
+		pdb, err := r.podDisruptionBudgetForHostproxy(hostproxy)
+		if err != nil {
+			return err
+		}
+		log.Info("Creating a new PodDisruptionBudget",
+			"PodDisruptionBudget.Namespace", pdb.Namespace, "PodDisruptionBudget.Name", pdb.Name)
+		return r.Create(ctx, pdb)
+	case !wanted && exists && metav1.IsControlledBy(found, hostproxy):
+		log.Info("Deleting the PodDisruptionBudget",
+			"PodDisruptionBudget.Namespace", found.Namespace, "PodDisruptionBudget.Name", found.Name)
+		return client.IgnoreNotFound(r.Delete(ctx, found))
+	}
+	return nil
+}
+
+// podDisruptionBudgetForHostproxy returns a Hostproxy PodDisruptionBudget object
+func (r *HostproxyReconciler) podDisruptionBudgetForHostproxy(
+	hostproxy *networkingv1.Hostproxy) (*policyv1.PodDisruptionBudget, error) {
+	minAvailable := intstr.FromInt32(1)
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      hostproxy.Name,
+			Namespace: hostproxy.Namespace,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: podtemplate.SelectorLabels(hostproxy.Name),
+			},
+		},
+	}
+
+	// Set the ownerRef for the PodDisruptionBudget
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(hostproxy, pdb, r.Scheme); err != nil {
+		return nil, err
+	}
+	return pdb, nil
+}
+
+// reconcileNetworkPolicy applies the NetworkPolicy restricting the access to the proxy pods when
+// sources are allowed in the spec, and deletes the one owned by the Hostproxy otherwise.
+func (r *HostproxyReconciler) reconcileNetworkPolicy(ctx context.Context, hostproxy *networkingv1.Hostproxy) error {
+	if len(hostproxy.Spec.AllowedSources) == 0 {
+		return r.deleteControlledObject(ctx, hostproxy, hostproxy.Name, &netv1.NetworkPolicy{})
+	}
+
+	policy, err := r.networkPolicyForHostproxy(hostproxy)
+	if err != nil {
+		return err
+	}
+
+	// A NetworkPolicy left by a former Hostproxy of the same name is deleted first, since the
+	// apply would leave it with two controllers
+	found := &netv1.NetworkPolicy{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(policy), found); client.IgnoreNotFound(err) != nil {
+		return err
+	} else if err == nil && ownedByStaleHostproxy(found, hostproxy) {
+		log.FromContext(ctx).Info("Recreating the NetworkPolicy of a former Hostproxy",
+			"NetworkPolicy.Namespace", found.Namespace, "NetworkPolicy.Name", found.Name)
+		if err := r.Delete(ctx, found); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	// The NetworkPolicy is applied server-side like the Service, so that the allowed sources
+	// are replaced as a whole when they change
+	return r.Patch(ctx, policy, client.Apply, client.FieldOwner(fieldOwner), client.ForceOwnership)
+}
+
+// networkPolicyForHostproxy returns a Hostproxy NetworkPolicy object, which only allows the
+// traffic from the allowed sources to reach the cluster port of the proxy pods
+func (r *HostproxyReconciler) networkPolicyForHostproxy(hostproxy *networkingv1.Hostproxy) (*netv1.NetworkPolicy, error) {
+	peers := make([]netv1.NetworkPolicyPeer, 0, len(hostproxy.Spec.AllowedSources))
+	for _, source := range hostproxy.Spec.AllowedSources {
+		if _, _, err := net.ParseCIDR(source); err == nil {
+			peers = append(peers, netv1.NetworkPolicyPeer{IPBlock: &netv1.IPBlock{CIDR: source}})
+			continue
+		}
+		selector, err := metav1.ParseToLabelSelector(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed source %q: %w", source, err)
+		}
+		peers = append(peers, netv1.NetworkPolicyPeer{NamespaceSelector: selector})
+	}
+
+	protocol := corev1.ProtocolTCP
+	port := intstr.FromInt32(hostproxy.Spec.ClusterPort)
+	policy := &netv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: netv1.SchemeGroupVersion.String(), Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      hostproxy.Name,
+			Namespace: hostproxy.Namespace,
+			Labels:    labelsForHostproxy(hostproxy.Name),
+		},
+		Spec: netv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: podtemplate.SelectorLabels(hostproxy.Name),
+			},
+			PolicyTypes: []netv1.PolicyType{netv1.PolicyTypeIngress},
+			Ingress: []netv1.NetworkPolicyIngressRule{{
+				Ports: []netv1.NetworkPolicyPort{{Protocol: &protocol, Port: &port}},
+				From:  peers,
+			}},
+		},
+	}
+
+	// Set the ownerRef for the NetworkPolicy
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(hostproxy, policy, r.Scheme); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// reconcileServiceAccount creates the ServiceAccount of the Hostproxy when requested, and deletes
+// the one owned by the Hostproxy when it isn't needed anymore.
+func (r *HostproxyReconciler) reconcileServiceAccount(ctx context.Context, hostproxy *networkingv1.Hostproxy) error {
+	log := log.FromContext(ctx)
+
+	name := podtemplate.ServiceAccountName(hostproxy)
+	if name == "" {
+		name = hostproxy.Name
+	}
+
+	found := &corev1.ServiceAccount{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: hostproxy.Namespace}, found)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	// A ServiceAccount left by a former Hostproxy of the same name is recreated
+	if exists && ownedByStaleHostproxy(found, hostproxy) {
+		log.Info("Recreating the ServiceAccount of a former Hostproxy",
+			"ServiceAccount.Namespace", found.Namespace, "ServiceAccount.Name", found.Name)
+		if err := r.Delete(ctx, found); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		exists = false
+	}
+
+	switch {
+	case hostproxy.Spec.CreateServiceAccount && exists:
+		return r.adoptObject(ctx, hostproxy, found)
+	case hostproxy.Spec.CreateServiceAccount && !exists:
+		sa, err := r.serviceAccountForHostproxy(hostproxy)
+		if err != nil {
+			return err
+		}
+		log.Info("Creating a new ServiceAccount", "ServiceAccount.Namespace", sa.Namespace, "ServiceAccount.Name", sa.Name)
+		return r.Create(ctx, sa)
+	case !hostproxy.Spec.CreateServiceAccount && exists && metav1.IsControlledBy(found, hostproxy):
+		log.Info("Deleting the ServiceAccount", "ServiceAccount.Namespace", found.Namespace, "ServiceAccount.Name", found.Name)
+		return client.IgnoreNotFound(r.Delete(ctx, found))
+	}
+	return nil
+}
+
+// serviceAccountForHostproxy returns a Hostproxy ServiceAccount object
+func (r *HostproxyReconciler) serviceAccountForHostproxy(
+	hostproxy *networkingv1.Hostproxy) (*corev1.ServiceAccount, error) {
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podtemplate.ServiceAccountName(hostproxy),
+			Namespace: hostproxy.Namespace,
+		},
+	}
+
+	// Set the ownerRef for the ServiceAccount
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(hostproxy, sa, r.Scheme); err != nil {
+		return nil, err
+	}
+	return sa, nil
+}
+
+// containerByName returns the container of the given name, or nil when there is none
+func containerByName(containers []corev1.Container, name string) *corev1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// removeContainer returns the containers without the one of the given name
+func removeContainer(containers []corev1.Container, name string) []corev1.Container {
+	kept := make([]corev1.Container, 0, len(containers))
+	for _, container := range containers {
+		if container.Name != name {
+			kept = append(kept, container)
+		}
+	}
+	return kept
+}
+
+// deploymentNameForHostproxy returns the name of the Deployment of the single host, which is the
+// name of the Hostproxy followed by its name suffix
+func deploymentNameForHostproxy(hostproxy *networkingv1.Hostproxy) string {
+	return hostproxy.Name + hostproxy.Spec.NameSuffix
+}
+
+// serviceNameForHostproxy returns the name of the Service, which is the name of the Hostproxy
+// followed by its name suffix unless set in the spec
+func serviceNameForHostproxy(hostproxy *networkingv1.Hostproxy) string {
+	if hostproxy.Spec.ServiceName != "" {
+		return hostproxy.Spec.ServiceName
+	}
+	return hostproxy.Name + hostproxy.Spec.NameSuffix
+}
+
+// serviceNamespaceForHostproxy returns the namespace of the Service, which is the namespace of the
+// Hostproxy unless set in the spec
+func serviceNamespaceForHostproxy(hostproxy *networkingv1.Hostproxy) string {
+	if hostproxy.Spec.ServiceNamespace != "" {
+		return hostproxy.Spec.ServiceNamespace
+	}
+	return hostproxy.Namespace
+}
+
+// managesCrossNamespaceService returns whether a Service of another namespace than the one of the
+// Hostproxy is managed by the Hostproxy
+func managesCrossNamespaceService(hostproxy *networkingv1.Hostproxy, svc *corev1.Service) bool {
+	return svc.Labels[ownerNamespaceLabel] == hostproxy.Namespace &&
+		svc.Labels["app.kubernetes.io/instance"] == hostproxy.Name
+}
+
+// restartCount returns the total number of restarts of the containers of the proxy pods
+func restartCount(pods []corev1.Pod) int32 {
+	var restarts int32
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts += status.RestartCount
+		}
+	}
+	return restarts
+}
+
+// restartThresholdForHostproxy returns the number of restarts above which the Hostproxy is degraded
+func restartThresholdForHostproxy(hostproxy *networkingv1.Hostproxy) int32 {
+	if hostproxy.Spec.RestartThreshold == nil {
+		return defaultRestartThreshold
+	}
+	return *hostproxy.Spec.RestartThreshold
+}
+
+// deploymentCondition returns the condition of the given type of a Deployment, or nil when there is none
+func deploymentCondition(dep *appsv1.Deployment, conditionType appsv1.DeploymentConditionType) *appsv1.DeploymentCondition {
+	for i := range dep.Status.Conditions {
+		if dep.Status.Conditions[i].Type == conditionType {
+			return &dep.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// foreignProxyPods returns the names of the pods which aren't run by the workloads of the Hostproxy.
+// The ReplicaSets of its Deployments are recognized by their names, made of the name of the
+// Deployment and of the hash of the pod template.
+func foreignProxyPods(hostproxy *networkingv1.Hostproxy, pods []corev1.Pod) []string {
+	var foreign []string
+	for _, pod := range pods {
+		owner := metav1.GetControllerOf(&pod)
+		owned := false
+		switch {
+		case owner == nil:
+		case owner.Kind == "DaemonSet":
+			owned = owner.Name == hostproxy.Name
+		case owner.Kind == "ReplicaSet":
+			deployment := deploymentNameForHostproxy(hostproxy)
+			if backend, ok := pod.Labels[backendLabel]; ok {
+				deployment = backendDeploymentName(hostproxy, networkingv1.Backend{Name: backend})
+			}
+			owned = owner.Name == deployment+"-"+pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+		}
+		if !owned {
+			foreign = append(foreign, pod.Name)
+		}
+	}
+	return foreign
+}
+
+// degradedProxyPods inspects the proxy pods and returns the reason and the message of the
+// Degraded condition when one of them prevents the proxy from working.
+func degradedProxyPods(pods []corev1.Pod) (string, string, bool) {
+	for _, pod := range pods {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse &&
+				condition.Reason == corev1.PodReasonUnschedulable {
+				return networkingv1.ReasonUnschedulable,
+					fmt.Sprintf("Proxy pod %s can't be scheduled: %s", pod.Name, condition.Message), true
+			}
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if waiting := status.State.Waiting; waiting != nil &&
+				(waiting.Reason == "ImagePullBackOff" || waiting.Reason == "ErrImagePull") {
+				return networkingv1.ReasonImageUnavailable,
+					fmt.Sprintf("Proxy pod %s can't pull the image %s (%s): %s", pod.Name, status.Image,
+						waiting.Reason, waiting.Message), true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// wantsService returns true if the Hostproxy requires a Service
+func wantsService(hostproxy *networkingv1.Hostproxy) bool {
+	return hostproxy.Spec.Mode != networkingv1.DaemonSetMode &&
+		(hostproxy.Spec.CreateService == nil || *hostproxy.Spec.CreateService)
+}
+
+// wantsPodDisruptionBudget returns true if the Hostproxy requires a PodDisruptionBudget
+func wantsPodDisruptionBudget(hostproxy *networkingv1.Hostproxy) bool {
+	return hostproxy.Spec.Mode != networkingv1.DaemonSetMode && podtemplate.Replicas(hostproxy) > 1 &&
+		(hostproxy.Spec.CreatePDB == nil || *hostproxy.Spec.CreatePDB)
+}
+
+// ownedResourcesForHostproxy returns the kind/name of the child resources managed for the Hostproxy
+func ownedResourcesForHostproxy(hostproxy *networkingv1.Hostproxy) []string {
+	owned := []string{"Deployment/" + deploymentNameForHostproxy(hostproxy)}
+	if hostproxy.Spec.Mode == networkingv1.DaemonSetMode {
+		owned = []string{"DaemonSet/" + hostproxy.Name}
+	} else if len(hostproxy.Spec.Backends) > 0 {
+		owned = nil
+		for _, backend := range hostproxy.Spec.Backends {
+			owned = append(owned, "Deployment/"+backendDeploymentName(hostproxy, backend))
+		}
+	}
+	if wantsService(hostproxy) {
+		owned = append(owned, "Service/"+serviceNameForHostproxy(hostproxy))
+	}
+	if wantsPodDisruptionBudget(hostproxy) {
+		owned = append(owned, "PodDisruptionBudget/"+hostproxy.Name)
+	}
+	if hostproxy.Spec.CreateServiceAccount {
+		owned = append(owned, "ServiceAccount/"+podtemplate.ServiceAccountName(hostproxy))
+	}
+	if len(hostproxy.Spec.AllowedSources) > 0 {
+		owned = append(owned, "NetworkPolicy/"+hostproxy.Name)
+	}
+	return owned
+}
+
+// replicasForBackends splits the replicas of a Hostproxy between its backends in proportion of
+// their weights. The replicas left by the rounding go to the backends with the largest remainders.
+func replicasForBackends(hostproxy *networkingv1.Hostproxy) []int32 {
+	backends := hostproxy.Spec.Backends
+	replicas := make([]int32, len(backends))
+	var weights int32
+	for _, backend := range backends {
+		weights += backend.EffectiveWeight()
+	}
+	if weights == 0 {
+		return replicas
+	}
+
+	total := podtemplate.Replicas(hostproxy)
+	assigned := int32(0)
+	order := make([]int, len(backends))
+	for i, backend := range backends {
+		replicas[i] = total * backend.EffectiveWeight() / weights
+		assigned += replicas[i]
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return total*backends[order[a]].EffectiveWeight()%weights > total*backends[order[b]].EffectiveWeight()%weights
+	})
+	for i := 0; assigned < total; i++ {
+		replicas[order[i]]++
+		assigned++
+	}
+	return replicas
+}
+
+// backendDeploymentName returns the name of the Deployment of a backend of a Hostproxy
+func backendDeploymentName(hostproxy *networkingv1.Hostproxy, backend networkingv1.Backend) string {
+	return hostproxy.Name + "-" + backend.Name
+}
+
+// labelsForHostproxy returns the labels of the resources, which add the version of the Operand
+// image to the labels selecting them
+func labelsForHostproxy(name string) map[string]string {
+	image, _ := imageForHostproxy()
+	return podtemplate.Labels(name, image)
+}
+
+// selectsHostproxy returns whether a selector selects the proxy pods of a Hostproxy by the
+// labels selecting them. The other keys are ignored, like the version selected by the former
+// releases of the controller.
+func selectsHostproxy(selector *metav1.LabelSelector, name string) bool {
+	if selector == nil || len(selector.MatchExpressions) > 0 {
+		return false
+	}
+	for key, value := range podtemplate.SelectorLabels(name) {
+		if selector.MatchLabels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// imageForHostproxy gets the Operand image which is managed by this controller
+// from the HOSTPROXY_IMAGE environment variable defined in the config/manager/manager.yaml
+func imageForHostproxy() (string, error) {
+	var imageEnvVar = "HOSTPROXY_IMAGE"
+	image, found := os.LookupEnv(imageEnvVar)
+	if !found {
+		return "", fmt.Errorf("Unable to find %s environment variable with the image", imageEnvVar)
+	}
+	return image, nil
+}
+
+// requeueInterval returns the delay before a reconciliation is requeued to check the state of new children
+func (r *HostproxyReconciler) requeueInterval() time.Duration {
+	if r.RequeueInterval == 0 {
+		return defaultRequeueInterval
+	}
+	return r.RequeueInterval
+}
+
+// watchesNamespace returns true if the Hostproxies of the given namespace must be reconciled
+func (r *HostproxyReconciler) watchesNamespace(namespace string) bool {
+	if len(r.WatchNamespaces) == 0 {
+		return true
+	}
+	for _, ns := range r.WatchNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// hostproxyPredicate filters the Hostproxy events which trigger a reconciliation.
+// Only changes of the generation and of the annotations are considered, so that the status
+// updates performed by the controller itself don't enqueue the resource again, while
+// resuming a paused Hostproxy does.
+func hostproxyPredicate() predicate.Predicate {
+	return predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})
+}
+
+// hostproxyRateLimiter returns the rate limiter of the controller queue.
+// It combines a per-item exponential backoff capped at maxDelay with an overall bucket so that
+// bursts of changes don't flood the API server.
+func hostproxyRateLimiter(maxDelay time.Duration) workqueue.RateLimiter {
+	if maxDelay == 0 {
+		maxDelay = rateLimiterMaxDelay
+	}
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(rateLimiterBaseDelay, maxDelay),
+		// 10 qps, 100 bucket size. This is only for retry speed and its only the overall factor (not per item)
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+}
+
+// hostproxiesForConfigMap returns the requests of the Hostproxies configured by a ConfigMap, so that
+// they are reconciled when it is updated
+func (r *HostproxyReconciler) hostproxiesForConfigMap(ctx context.Context, configMap client.Object) []reconcile.Request {
+	return r.hostproxiesReferencing(ctx, configMap, func(hostproxy *networkingv1.Hostproxy) *corev1.LocalObjectReference {
+		return hostproxy.Spec.ConfigMapRef
+	})
+}
+
+// hostproxiesForSecret returns the requests of the Hostproxies mounting a Secret, so that they are
+// reconciled when it is rotated
+func (r *HostproxyReconciler) hostproxiesForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	return r.hostproxiesReferencing(ctx, secret, func(hostproxy *networkingv1.Hostproxy) *corev1.LocalObjectReference {
+		return hostproxy.Spec.SecretRef
+	})
+}
+
+// hostproxiesReferencing returns the requests of the Hostproxies of the namespace of an object
+// whose reference returned by ref names the object
+func (r *HostproxyReconciler) hostproxiesReferencing(ctx context.Context, obj client.Object,
+	ref func(*networkingv1.Hostproxy) *corev1.LocalObjectReference) []reconcile.Request {
+	hostproxies := &networkingv1.HostproxyList{}
+	if err := r.List(ctx, hostproxies, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list the Hostproxies referencing an object",
+			"Namespace", obj.GetNamespace(), "Name", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range hostproxies.Items {
+		hostproxy := &hostproxies.Items[i]
+		if reference := ref(hostproxy); reference != nil && reference.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(hostproxy)})
+		}
+	}
+	return requests
+}
+
+// hostproxyForProxyPod returns the request of the Hostproxy running a proxy pod when its Service
+// lives in another namespace, so that the Endpoints listing the proxy pods follow their restarts
+func (r *HostproxyReconciler) hostproxyForProxyPod(ctx context.Context, pod client.Object) []reconcile.Request {
+	name := pod.GetLabels()["app.kubernetes.io/instance"]
+	for key, value := range podtemplate.SelectorLabels(name) {
+		if pod.GetLabels()[key] != value {
+			return nil
+		}
+	}
+
+	hostproxy := &networkingv1.Hostproxy{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: pod.GetNamespace()}, hostproxy); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "Failed to get the Hostproxy of a proxy pod",
+				"Pod.Namespace", pod.GetNamespace(), "Pod.Name", pod.GetName())
+		}
+		return nil
+	}
+	if !wantsService(hostproxy) || serviceNamespaceForHostproxy(hostproxy) == hostproxy.Namespace {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(hostproxy)}}
+}
+
+// setChecksums records in the status the checksums of the data of the ConfigMap and of the Secret
+// mounted in the proxy pods. A checksum is left empty when its object doesn't exist.
+func (r *HostproxyReconciler) setChecksums(ctx context.Context, hostproxy *networkingv1.Hostproxy) error {
+	hostproxy.Status.ConfigChecksum = ""
+	if ref := hostproxy.Spec.ConfigMapRef; ref != nil {
+		configMap := &corev1.ConfigMap{}
+		err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: hostproxy.Namespace}, configMap)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if err == nil {
+			if hostproxy.Status.ConfigChecksum, err = specHash([]interface{}{configMap.Data, configMap.BinaryData}); err != nil {
+				return err
+			}
+		}
+	}
+
+	hostproxy.Status.CredentialsChecksum = ""
+	if ref := hostproxy.Spec.SecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: hostproxy.Namespace}, secret)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if err == nil {
+			if hostproxy.Status.CredentialsChecksum, err = specHash(secret.Data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
-// Note that the Deployment will be also watched in order to ensure its
-// desirable state on the cluster
+// Note that the Deployment and the Service will be also watched in order to ensure
+// their desirable state on the cluster
 func (r *HostproxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Time the calls to the API server, so that the slow reconciliations can be explained
+	r.Client = timedClient{Client: r.Client}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&networkingv1.Hostproxy{}).
+		For(&networkingv1.Hostproxy{}, builder.WithPredicates(hostproxyPredicate())).
 		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.DaemonSet{}).
+		Owns(&corev1.Service{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Owns(&netv1.NetworkPolicy{}).
+		Owns(&batchv1.Job{}).
+		Owns(&corev1.ServiceAccount{}).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.hostproxiesForConfigMap)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.hostproxiesForSecret)).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.hostproxyForProxyPod)).
+		WithOptions(controller.Options{RateLimiter: hostproxyRateLimiter(r.MaxBackoff)}).
+		WithEventFilter(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			return r.watchesNamespace(object.GetNamespace())
+		})).
 		Complete(r)
 }
@@ -22,17 +22,33 @@ import (
 	"os"
 	"time"
 
+	"github.com/go-logr/logr/funcr"
 	//nolint:golint
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	networkingv1 "github.com/raw1z/hostproxy/api/v1"
+	"github.com/raw1z/hostproxy/podtemplate"
 )
 
 var _ = Describe("Hostproxy controller", func() {
@@ -137,9 +153,9 @@ var _ = Describe("Hostproxy controller", func() {
 					expectedLatestStatusCondition := metav1.Condition{
 						Type:   typeAvailableHostproxy,
 						Status: metav1.ConditionTrue,
-						Reason: "Reconciling",
+						Reason: networkingv1.ReasonDeploymentCreated,
 						Message: fmt.Sprintf(
-							"Deployment for custom resource (%s) with 1 replicas created successfully",
+							"Deployment for custom resource (%s) created successfully",
 							hostproxy.Name),
 					}
 					if latestStatusCondition != expectedLatestStatusCondition {
@@ -151,3 +167,2672 @@ var _ = Describe("Hostproxy controller", func() {
 		})
 	})
 })
+
+var _ = Describe("Hostproxy reconciliation", func() {
+	var (
+		ctx                 context.Context
+		namespace           string
+		hostproxyReconciler *HostproxyReconciler
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		By("Setting the Image ENV VAR which stores the Operand image")
+		Expect(os.Setenv("HOSTPROXY_IMAGE", "example.com/image:test")).To(Succeed())
+
+		By("Creating a dedicated Namespace to perform the test")
+		namespace = createTestNamespace(ctx)
+
+		hostproxyReconciler = &HostproxyReconciler{
+			Client:   k8sClient,
+			Scheme:   k8sClient.Scheme(),
+			Recorder: record.NewFakeRecorder(100),
+		}
+	})
+
+	AfterEach(func() {
+		By("Removing the Image ENV VAR which stores the Operand image")
+		_ = os.Unsetenv("HOSTPROXY_IMAGE")
+	})
+
+	It("should only enqueue the Hostproxy when its spec changes", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "predicate", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		predicate := hostproxyPredicate()
+
+		By("Updating the status subresource")
+		old := hostproxy.DeepCopy()
+		meta.SetStatusCondition(&hostproxy.Status.Conditions, metav1.Condition{Type: typeAvailableHostproxy,
+			Status: metav1.ConditionUnknown, Reason: "Reconciling", Message: "Starting reconciliation"})
+		Expect(k8sClient.Status().Update(ctx, hostproxy)).To(Succeed())
+		Expect(predicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: hostproxy})).To(BeFalse())
+
+		By("Updating the spec")
+		old = hostproxy.DeepCopy()
+		hostproxy.Spec.HostPort = 10542
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		Expect(predicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: hostproxy})).To(BeTrue())
+
+		By("Resuming the Hostproxy")
+		old = hostproxy.DeepCopy()
+		hostproxy.Annotations = map[string]string{networkingv1.PausedAnnotation: "false"}
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		Expect(predicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: hostproxy})).To(BeTrue())
+	})
+
+	It("should report the proxy image in the status", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "image", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(hostproxy.Status.Image).To(Equal("example.com/image:test"))
+		Expect(hostproxy.Status.ImageVersion).To(Equal("test"))
+	})
+
+	It("should run the proxy in the network namespace of the host when requested", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "host-network", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			HostNetwork: true,
+		})
+
+		dep, err := hostproxyReconciler.deploymentForHostproxy(hostproxy)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(dep.Spec.Template.Spec.HostNetwork).To(BeTrue())
+		Expect(dep.Spec.Template.Spec.DNSPolicy).To(Equal(corev1.DNSClusterFirstWithHostNet))
+	})
+
+	It("should declare the host port on the proxy container when requested", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "container-host-port", networkingv1.HostproxySpec{
+			HostPort:             10541,
+			ClusterPort:          80,
+			UseContainerHostPort: true,
+		})
+
+		dep, err := hostproxyReconciler.deploymentForHostproxy(hostproxy)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(dep.Spec.Template.Spec.Containers[0].Ports).To(ConsistOf(corev1.ContainerPort{
+			ContainerPort: 80,
+			HostPort:      10541,
+			Protocol:      corev1.ProtocolTCP,
+		}))
+	})
+
+	It("should only reconcile the Hostproxies of the watched namespaces", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "watch-namespace", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		By("Reconciling with the namespace out of scope")
+		hostproxyReconciler.WatchNamespaces = []string{"another-namespace"}
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		By("Reconciling with the namespace in scope")
+		hostproxyReconciler.WatchNamespaces = []string{"another-namespace", namespace}
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &appsv1.Deployment{})).To(Succeed())
+	})
+
+	It("should record the observed generation in the status", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "observed-generation", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		Expect(hostproxy.Status.ObservedGeneration).To(Equal(hostproxy.Generation))
+
+		By("Bumping the generation of the spec")
+		previousGeneration := hostproxy.Generation
+		hostproxy.Spec.HostPort = 10542
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		Expect(hostproxy.Generation).To(BeNumerically(">", previousGeneration))
+
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		Expect(hostproxy.Status.ObservedGeneration).To(Equal(hostproxy.Generation))
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeAvailableHostproxy)
+		Expect(condition).To(Not(BeNil()))
+		Expect(condition.ObservedGeneration).To(Equal(hostproxy.Generation))
+	})
+
+	It("should pass the environment of the spec to the proxy container", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "env", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			Env: []corev1.EnvVar{
+				{Name: "BUFFER_SIZE", Value: "4096"},
+				{Name: "PORTS", Value: "1:1"},
+			},
+		})
+
+		dep, err := hostproxyReconciler.deploymentForHostproxy(hostproxy)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(dep.Spec.Template.Spec.Containers[0].Env).To(ConsistOf(
+			corev1.EnvVar{Name: "PORTS", Value: "80:10541"},
+			corev1.EnvVar{Name: "LOG_LEVEL", Value: "info"},
+			corev1.EnvVar{Name: "BUFFER_SIZE", Value: "4096"},
+		))
+	})
+
+	It("should configure the verbosity of the proxy", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "log-level", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			LogLevel:    "warn",
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+			corev1.EnvVar{Name: "LOG_LEVEL", Value: "warn"}))
+
+		By("Changing the log level of the spec")
+		hostproxy.Spec.LogLevel = "debug"
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+			corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+	})
+
+	It("should protect multi-replica proxies with a PodDisruptionBudget", func() {
+		replicas := int32(2)
+		hostproxy := createTestHostproxy(ctx, namespace, "pdb", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			Replicas:    &replicas,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		pdb := &policyv1.PodDisruptionBudget{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), pdb)).To(Succeed())
+		Expect(metav1.IsControlledBy(pdb, hostproxy)).To(BeTrue())
+		Expect(pdb.Spec.MinAvailable.IntValue()).To(Equal(1))
+		Expect(pdb.Spec.Selector.MatchLabels).To(Equal(podtemplate.SelectorLabels(hostproxy.Name)))
+
+		By("Opting out of the PodDisruptionBudget")
+		createPDB := false
+		hostproxy.Spec.CreatePDB = &createPDB
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), pdb)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should run the proxy pods with the ServiceAccount of the spec", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "service-account", networkingv1.HostproxySpec{
+			HostPort:           10541,
+			ClusterPort:        80,
+			ServiceAccountName: "proxy",
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.ServiceAccountName).To(Equal("proxy"))
+
+		By("Checking that no ServiceAccount has been created")
+		err := k8sClient.Get(ctx, types.NamespacedName{Name: "proxy", Namespace: namespace}, &corev1.ServiceAccount{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should only mount the token of the ServiceAccount when requested", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "automount-token", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.AutomountServiceAccountToken).NotTo(BeNil())
+		Expect(*found.Spec.Template.Spec.AutomountServiceAccountToken).To(BeFalse())
+
+		By("Requesting the token")
+		automount := true
+		hostproxy.Spec.AutomountServiceAccountToken = &automount
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(*found.Spec.Template.Spec.AutomountServiceAccountToken).To(BeTrue())
+	})
+
+	It("should create a ServiceAccount dedicated to the proxy pods when requested", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "created-service-account", networkingv1.HostproxySpec{
+			HostPort:             10541,
+			ClusterPort:          80,
+			CreateServiceAccount: true,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		sa := &corev1.ServiceAccount{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), sa)).To(Succeed())
+		Expect(metav1.IsControlledBy(sa, hostproxy)).To(BeTrue())
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.ServiceAccountName).To(Equal(sa.Name))
+	})
+
+	It("should split the replicas between the backends in proportion of their weights", func() {
+		replicas := int32(4)
+		blueWeight, greenWeight := int32(3), int32(1)
+		hostproxy := createTestHostproxy(ctx, namespace, "backends", networkingv1.HostproxySpec{
+			ClusterPort: 80,
+			Replicas:    &replicas,
+			Backends: []networkingv1.Backend{
+				{Name: "blue", HostPort: 10541, Weight: &blueWeight},
+				{Name: "green", HostPort: 10542, Weight: &greenWeight},
+			},
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		svc := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), svc)).To(Succeed())
+		for name, expected := range map[string]int32{"backends-blue": 3, "backends-green": 1} {
+			found := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, found)).To(Succeed())
+			Expect(metav1.IsControlledBy(found, hostproxy)).To(BeTrue())
+			Expect(*found.Spec.Replicas).To(Equal(expected))
+			for key, value := range svc.Spec.Selector {
+				Expect(found.Spec.Template.Labels).To(HaveKeyWithValue(key, value))
+			}
+		}
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		By("Removing a backend")
+		hostproxy.Spec.Backends = hostproxy.Spec.Backends[:1]
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "backends-blue", Namespace: namespace}, found)).To(Succeed())
+		Expect(*found.Spec.Replicas).To(Equal(replicas))
+		err = k8sClient.Get(ctx, types.NamespacedName{Name: "backends-green", Namespace: namespace}, &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should spread the proxy pods across the zones", func() {
+		replicas := int32(2)
+		hostproxy := createTestHostproxy(ctx, namespace, "topology-spread", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			Replicas:    &replicas,
+		})
+
+		dep, err := hostproxyReconciler.deploymentForHostproxy(hostproxy)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(dep.Spec.Template.Spec.TopologySpreadConstraints).To(HaveLen(1))
+		Expect(dep.Spec.Template.Spec.TopologySpreadConstraints[0].TopologyKey).To(Equal(corev1.LabelTopologyZone))
+
+		By("Setting the constraints in the spec")
+		constraint := corev1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       corev1.LabelHostname,
+			WhenUnsatisfiable: corev1.DoNotSchedule,
+		}
+		hostproxy.Spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{constraint}
+
+		dep, err = hostproxyReconciler.deploymentForHostproxy(hostproxy)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(dep.Spec.Template.Spec.TopologySpreadConstraints).To(ConsistOf(constraint))
+	})
+
+	It("should co-locate the proxy pods with the target pods", func() {
+		selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "database"}}
+		hostproxy := createTestHostproxy(ctx, namespace, "target-pods", networkingv1.HostproxySpec{
+			HostPort:          10541,
+			ClusterPort:       80,
+			TargetPodSelector: selector,
+		})
+
+		dep, err := hostproxyReconciler.deploymentForHostproxy(hostproxy)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(dep.Spec.Template.Spec.Affinity).NotTo(BeNil())
+		Expect(dep.Spec.Template.Spec.Affinity.PodAffinity).NotTo(BeNil())
+		terms := dep.Spec.Template.Spec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		Expect(terms).To(HaveLen(1))
+		Expect(terms[0].LabelSelector).To(Equal(selector))
+		Expect(terms[0].TopologyKey).To(Equal(corev1.LabelHostname))
+	})
+
+	It("should remove the Service when it is not wanted anymore", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "optional-service", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &corev1.Service{})).To(Succeed())
+
+		By("Disabling the Service")
+		createService := false
+		hostproxy.Spec.CreateService = &createService
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &corev1.Service{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should list the owned child resources in the status", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "owned-resources", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(hostproxy.Status.OwnedResources).To(ConsistOf(
+			"Deployment/owned-resources",
+			"Service/owned-resources",
+		))
+	})
+
+	It("should recreate a Service deleted manually", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "deleted-service", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		By("Deleting the Service")
+		svc := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), svc)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, svc)).To(Succeed())
+
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), svc)).To(Succeed())
+		Expect(metav1.IsControlledBy(svc, hostproxy)).To(BeTrue())
+	})
+
+	It("should restore the selector of the Service when it is edited", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "service-selector", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		By("Corrupting the selector of the Service")
+		svc := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), svc)).To(Succeed())
+		svc.Spec.Selector["app.kubernetes.io/instance"] = "other"
+		svc.Spec.Selector["tier"] = "frontend"
+		Expect(k8sClient.Update(ctx, svc)).To(Succeed())
+
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), svc)).To(Succeed())
+		Expect(svc.Spec.Selector).To(Equal(podtemplate.SelectorLabels(hostproxy.Name)))
+	})
+
+	It("should apply the startup probe of the spec", func() {
+		probe := &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(80)},
+			},
+			FailureThreshold: 60,
+		}
+		hostproxy := createTestHostproxy(ctx, namespace, "startup-probe", networkingv1.HostproxySpec{
+			HostPort:     10541,
+			ClusterPort:  80,
+			StartupProbe: probe,
+		})
+
+		dep, err := hostproxyReconciler.deploymentForHostproxy(hostproxy)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(dep.Spec.Template.Spec.Containers[0].StartupProbe).To(Equal(hostproxy.Spec.StartupProbe))
+	})
+
+	It("should derive the startup probe from the liveness probe", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "derived-startup-probe", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			LivenessProbe: &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(80)},
+				},
+			},
+		})
+
+		dep, err := hostproxyReconciler.deploymentForHostproxy(hostproxy)
+		Expect(err).To(Not(HaveOccurred()))
+		startupProbe := dep.Spec.Template.Spec.Containers[0].StartupProbe
+		Expect(startupProbe).To(Not(BeNil()))
+		Expect(startupProbe.ProbeHandler).To(Equal(hostproxy.Spec.LivenessProbe.ProbeHandler))
+		Expect(startupProbe.FailureThreshold).To(Equal(int32(30)))
+	})
+
+	It("should pass IPv6 host addresses to the proxy", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "ipv6", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			HostAddress: "2001:db8::1",
+		})
+		Expect(podtemplate.Ports(hostproxy)).To(Equal("80:[2001:db8::1]:10541"))
+
+		hostproxy.Spec.HostAddress = "[2001:db8::1]"
+		Expect(podtemplate.Ports(hostproxy)).To(Equal("80:[2001:db8::1]:10541"))
+
+		hostproxy.Spec.HostAddress = "192.0.2.1"
+		Expect(podtemplate.Ports(hostproxy)).To(Equal("80:192.0.2.1:10541"))
+	})
+
+	It("should render the port mapping with a custom variable name and format", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "custom-ports", networkingv1.HostproxySpec{
+			HostPort:     10541,
+			ClusterPort:  80,
+			EnvVarName:   "PROXY_PORTS",
+			EnvVarFormat: "{{.ClusterPort}}->{{.HostPort}}",
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), deployment)).To(Succeed())
+		env := deployment.Spec.Template.Spec.Containers[0].Env
+		Expect(env).To(ContainElement(corev1.EnvVar{Name: "PROXY_PORTS", Value: "80->10541"}))
+		Expect(env).NotTo(ContainElement(HaveField("Name", "PORTS")))
+
+		hostproxy.Spec.HostAddress = "2001:db8::1"
+		hostproxy.Spec.EnvVarFormat = "{{.HostAddress}}/{{.HostPort}}={{.ClusterPort}}"
+		Expect(podtemplate.Ports(hostproxy)).To(Equal("2001:db8::1/10541=80"))
+	})
+
+	It("should mount the TUN device into the proxy container", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "device-tun", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			DeviceTun:   true,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), deployment)).To(Succeed())
+		deviceType := corev1.HostPathCharDev
+		Expect(deployment.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+			Name: "dev-net-tun",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: "/dev/net/tun", Type: &deviceType},
+			},
+		}))
+		Expect(deployment.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name:      "dev-net-tun",
+			MountPath: "/dev/net/tun",
+		}))
+	})
+
+	It("should mount the ConfigMap configuring the proxy", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "config-map", networkingv1.HostproxySpec{
+			HostPort:     10541,
+			ClusterPort:  80,
+			ConfigMapRef: &corev1.LocalObjectReference{Name: "proxy-config"},
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), deployment)).To(Succeed())
+		Expect(deployment.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+			Name: "config",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "proxy-config"},
+				},
+			},
+		}))
+		container := deployment.Spec.Template.Spec.Containers[0]
+		Expect(container.VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name:      "config",
+			MountPath: "/etc/hostproxy",
+			ReadOnly:  true,
+		}))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "CONFIG_PATH", Value: "/etc/hostproxy"}))
+
+		By("Reporting the missing ConfigMap")
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeDegradedHostproxy)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(networkingv1.ReasonConfigMapNotFound))
+
+		By("Creating the ConfigMap")
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "proxy-config", Namespace: namespace},
+			Data:       map[string]string{"proxy.conf": "listen 80"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+		Expect(hostproxyReconciler.hostproxiesForConfigMap(ctx, configMap)).To(ConsistOf(
+			reconcile.Request{NamespacedName: client.ObjectKeyFromObject(hostproxy)}))
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		condition = meta.FindStatusCondition(hostproxy.Status.Conditions, typeDegradedHostproxy)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+	})
+
+	It("should roll out the proxy pods when their configuration is updated", func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "rollout-config", Namespace: namespace},
+			Data:       map[string]string{"proxy.conf": "listen 80"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+		hostproxy := createTestHostproxy(ctx, namespace, "config-rollout", networkingv1.HostproxySpec{
+			HostPort:     10541,
+			ClusterPort:  80,
+			ConfigMapRef: &corev1.LocalObjectReference{Name: "rollout-config"},
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), deployment)).To(Succeed())
+		checksum := deployment.Spec.Template.Annotations[podtemplate.ConfigChecksumAnnotation]
+		Expect(checksum).NotTo(BeEmpty())
+		Expect(hostproxy.Status.ConfigChecksum).To(Equal(checksum))
+
+		By("Updating the configuration")
+		configMap.Data["proxy.conf"] = "listen 8080"
+		Expect(k8sClient.Update(ctx, configMap)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		updated := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), updated)).To(Succeed())
+		Expect(updated.Generation).To(BeNumerically(">", deployment.Generation))
+		Expect(updated.Spec.Template.Annotations[podtemplate.ConfigChecksumAnnotation]).NotTo(Equal(checksum))
+		Expect(updated.Spec.Template.Annotations[podtemplate.ConfigChecksumAnnotation]).To(Equal(hostproxy.Status.ConfigChecksum))
+	})
+
+	It("should roll out the proxy pods when their credentials are rotated", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "proxy-credentials", Namespace: namespace},
+			Data:       map[string][]byte{"password": []byte("s3cr3t")},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		hostproxy := createTestHostproxy(ctx, namespace, "credentials", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			SecretRef:   &corev1.LocalObjectReference{Name: "proxy-credentials"},
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), deployment)).To(Succeed())
+		Expect(deployment.Spec.Template.Spec.Volumes).To(ContainElement(corev1.Volume{
+			Name: "credentials",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: "proxy-credentials"},
+			},
+		}))
+		container := deployment.Spec.Template.Spec.Containers[0]
+		Expect(container.VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name:      "credentials",
+			MountPath: "/var/run/secrets/hostproxy",
+			ReadOnly:  true,
+		}))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "CREDS_PATH", Value: "/var/run/secrets/hostproxy"}))
+		checksum := deployment.Spec.Template.Annotations[podtemplate.CredentialsChecksumAnnotation]
+		Expect(checksum).NotTo(BeEmpty())
+		Expect(hostproxy.Status.CredentialsChecksum).To(Equal(checksum))
+
+		By("Rotating the credentials")
+		secret.Data["password"] = []byte("n3w-s3cr3t")
+		Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+		Expect(hostproxyReconciler.hostproxiesForSecret(ctx, secret)).To(ConsistOf(
+			reconcile.Request{NamespacedName: client.ObjectKeyFromObject(hostproxy)}))
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		updated := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), updated)).To(Succeed())
+		Expect(updated.Generation).To(BeNumerically(">", deployment.Generation))
+		Expect(updated.Spec.Template.Annotations[podtemplate.CredentialsChecksumAnnotation]).NotTo(Equal(checksum))
+		Expect(updated.Spec.Template.Annotations[podtemplate.CredentialsChecksumAnnotation]).To(
+			Equal(hostproxy.Status.CredentialsChecksum))
+	})
+
+	It("should publish the addresses of the proxy pods which aren't ready when requested", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "not-ready-addresses", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		svc := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), svc)).To(Succeed())
+		Expect(svc.Spec.PublishNotReadyAddresses).To(BeFalse())
+
+		By("Publishing the addresses which aren't ready")
+		hostproxy.Spec.PublishNotReadyAddresses = true
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), svc)).To(Succeed())
+		Expect(svc.Spec.PublishNotReadyAddresses).To(BeTrue())
+	})
+
+	It("should configure the IP family policy of the Service", func() {
+		policy := corev1.IPFamilyPolicyPreferDualStack
+		hostproxy := createTestHostproxy(ctx, namespace, "dual-stack", networkingv1.HostproxySpec{
+			HostPort:       10541,
+			ClusterPort:    80,
+			IPFamilyPolicy: &policy,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		svc := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), svc)).To(Succeed())
+		Expect(svc.Spec.IPFamilyPolicy).To(Equal(&policy))
+	})
+
+	It("should requeue a reconciliation interrupted by its context", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "cancelled", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		result, err := hostproxyReconciler.Reconcile(cancelledCtx, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(hostproxy),
+		})
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(result.Requeue).To(BeTrue())
+	})
+
+	It("should requeue a reconciliation failing on a conflicting update", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "conflict", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		hostproxyReconciler.Client = conflictingStatusClient{Client: k8sClient}
+		result, err := hostproxyReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(hostproxy),
+		})
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(result.RequeueAfter).To(Equal(conflictRequeueInterval))
+	})
+
+	It("should not report a conflicting update of the Deployment as a failure", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "deployment-conflict", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		events := hostproxyReconciler.Recorder.(*record.FakeRecorder).Events
+		for len(events) > 0 {
+			<-events
+		}
+
+		By("Changing the spec while the Deployment is modified concurrently")
+		hostproxy.Spec.LogLevel = "debug"
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		hostproxyReconciler.Client = conflictingDeploymentClient{Client: k8sClient}
+		result, err := hostproxyReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(hostproxy),
+		})
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(result.RequeueAfter).To(Equal(conflictRequeueInterval))
+
+		for len(events) > 0 {
+			Expect(<-events).NotTo(HavePrefix("Warning"))
+		}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeAvailableHostproxy)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).NotTo(Equal(metav1.ConditionFalse))
+	})
+
+	It("should stop reconciling a Hostproxy of a terminating namespace", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "terminating", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		hostproxyReconciler.Client = terminatingNamespaceClient{Client: k8sClient}
+		for i := 0; i < 3; i++ {
+			result, err := hostproxyReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(hostproxy),
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(result).To(Equal(reconcile.Result{}))
+		}
+
+		deployment := &appsv1.Deployment{}
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), deployment)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should warn about the foreign pods matched by the selector of the proxy pods", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "foreign-pods", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		createTestPod(ctx, hostproxy, "foreign-pods-owned")
+
+		By("Creating a foreign pod carrying the labels of the proxy pods")
+		foreign := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foreign-pod",
+				Namespace: namespace,
+				Labels:    labelsForHostproxy(hostproxy.Name),
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "foreign", Image: "example.com/foreign:latest"}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, foreign)).To(Succeed())
+
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeSelectorConflictHostproxy)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(networkingv1.ReasonForeignPods))
+		Expect(condition.Message).To(ContainSubstring("foreign-pod"))
+		Expect(condition.Message).NotTo(ContainSubstring("foreign-pods-owned"))
+		Expect(hostproxyReconciler.Recorder.(*record.FakeRecorder).Events).To(
+			Receive(ContainSubstring(networkingv1.ReasonForeignPods)))
+
+		By("Deleting the foreign pod")
+		Expect(k8sClient.Delete(ctx, foreign)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		Expect(meta.FindStatusCondition(hostproxy.Status.Conditions, typeSelectorConflictHostproxy)).To(BeNil())
+	})
+
+	It("should report unschedulable proxy pods", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "unschedulable", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		By("Creating a proxy pod which can't be scheduled")
+		pod := createTestPod(ctx, hostproxy, "unschedulable-pod")
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type:    corev1.PodScheduled,
+			Status:  corev1.ConditionFalse,
+			Reason:  corev1.PodReasonUnschedulable,
+			Message: "0/3 nodes are available: 3 node(s) didn't match Pod's node affinity/selector.",
+		}}
+		Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeDegradedHostproxy)
+		Expect(condition).To(Not(BeNil()))
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(networkingv1.ReasonUnschedulable))
+		Expect(condition.Message).To(ContainSubstring("didn't match Pod's node affinity/selector"))
+	})
+
+	It("should apply the readiness gates of the spec to the proxy pods", func() {
+		gate := corev1.PodReadinessGate{ConditionType: "networking.raw1z.fr/host-port-reachable"}
+		hostproxy := createTestHostproxy(ctx, namespace, "readiness-gates", networkingv1.HostproxySpec{
+			HostPort:       10541,
+			ClusterPort:    80,
+			ReadinessGates: []corev1.PodReadinessGate{gate},
+		})
+
+		dep, err := hostproxyReconciler.deploymentForHostproxy(hostproxy)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(dep.Spec.Template.Spec.ReadinessGates).To(ConsistOf(gate))
+	})
+
+	It("should suspend a Hostproxy scaled to zero", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "suspended", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		Expect(meta.FindStatusCondition(hostproxy.Status.Conditions, typeSuspendedHostproxy)).To(BeNil())
+
+		By("Scaling the Hostproxy to zero")
+		replicas := int32(0)
+		hostproxy.Spec.Replicas = &replicas
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(*found.Spec.Replicas).To(Equal(int32(0)))
+
+		Expect(meta.IsStatusConditionTrue(hostproxy.Status.Conditions, typeSuspendedHostproxy)).To(BeTrue())
+		Expect(meta.FindStatusCondition(hostproxy.Status.Conditions, typeDegradedHostproxy)).To(BeNil())
+	})
+
+	It("should keep the foreign annotations of the Service", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "service-annotations", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		By("Annotating the Service from another controller")
+		svc := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), svc)).To(Succeed())
+		svc.Annotations = map[string]string{"mesh.example.com/inject": "true"}
+		Expect(k8sClient.Update(ctx, svc)).To(Succeed())
+
+		By("Applying a change of the spec to the Service")
+		hostproxy.Spec.ClusterPort = 8080
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), svc)).To(Succeed())
+		Expect(svc.Annotations).To(HaveKeyWithValue("mesh.example.com/inject", "true"))
+		Expect(svc.Spec.Ports).To(HaveLen(1))
+		Expect(svc.Spec.Ports[0].Port).To(Equal(int32(8080)))
+	})
+
+	It("should apply the DNS policy and config of the spec to the proxy pods", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "dns-config", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			HostNetwork: true,
+			DNSConfig:   &corev1.PodDNSConfig{Nameservers: []string{"192.0.2.53"}},
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.DNSPolicy).To(Equal(corev1.DNSClusterFirstWithHostNet))
+		Expect(found.Spec.Template.Spec.DNSConfig).NotTo(BeNil())
+		Expect(found.Spec.Template.Spec.DNSConfig.Nameservers).To(ConsistOf("192.0.2.53"))
+
+		By("Only using the nameservers of the DNS config")
+		hostproxy.Spec.DNSPolicy = corev1.DNSNone
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.DNSPolicy).To(Equal(corev1.DNSNone))
+		Expect(found.Spec.Template.Spec.DNSConfig.Nameservers).To(ConsistOf("192.0.2.53"))
+	})
+
+	It("should apply the update strategy of the spec to the Deployment", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "update-strategy", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		By("Switching to the Recreate strategy")
+		hostproxy.Spec.UpdateStrategy = &appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Strategy.Type).To(Equal(appsv1.RecreateDeploymentStrategyType))
+		Expect(found.Spec.Strategy.RollingUpdate).To(BeNil())
+	})
+
+	It("should apply maxSurge and maxUnavailable to the rolling update of the Deployment", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "max-surge", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		By("Forbidding the surge of the proxy pods")
+		maxSurge := intstr.FromInt32(0)
+		maxUnavailable := intstr.FromInt32(1)
+		hostproxy.Spec.MaxSurge = &maxSurge
+		hostproxy.Spec.MaxUnavailable = &maxUnavailable
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Strategy.Type).To(Equal(appsv1.RollingUpdateDeploymentStrategyType))
+		Expect(found.Spec.Strategy.RollingUpdate).NotTo(BeNil())
+		Expect(*found.Spec.Strategy.RollingUpdate.MaxSurge).To(Equal(maxSurge))
+		Expect(*found.Spec.Strategy.RollingUpdate.MaxUnavailable).To(Equal(maxUnavailable))
+	})
+
+	It("should roll out the liveness probe of the spec when it changes", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "liveness-update", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			LivenessProbe: &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(80)},
+				},
+				PeriodSeconds: 10,
+			},
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		By("Updating the liveness probe of the spec")
+		hostproxy.Spec.LivenessProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(8080)},
+			},
+			PeriodSeconds: 30,
+		}
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		proxy := found.Spec.Template.Spec.Containers[0]
+		Expect(proxy.LivenessProbe).NotTo(BeNil())
+		Expect(proxy.LivenessProbe.TCPSocket.Port).To(Equal(intstr.FromInt32(8080)))
+		Expect(proxy.LivenessProbe.PeriodSeconds).To(Equal(int32(30)))
+
+		By("Checking that the startup probe derived from the liveness probe follows it")
+		Expect(proxy.StartupProbe).NotTo(BeNil())
+		Expect(proxy.StartupProbe.TCPSocket.Port).To(Equal(intstr.FromInt32(8080)))
+	})
+
+	It("should apply the termination message policy of the spec to the proxy container", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "termination-message", networkingv1.HostproxySpec{
+			HostPort:                 10541,
+			ClusterPort:              80,
+			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers[0].TerminationMessagePolicy).To(
+			Equal(corev1.TerminationMessageFallbackToLogsOnError))
+	})
+
+	It("should roll out the proxy image when it changes", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "image-upgrade", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		uid := found.UID
+
+		By("Upgrading the proxy image")
+		Expect(os.Setenv("HOSTPROXY_IMAGE", "example.com/image:v2")).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		By("Checking that the Deployment is updated in place rather than recreated")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.UID).To(Equal(uid))
+		Expect(found.Spec.Template.Spec.Containers[0].Image).To(Equal("example.com/image:v2"))
+
+		By("Changing the image of the Deployment by hand")
+		found.Spec.Template.Spec.Containers[0].Image = "example.com/image:manual"
+		Expect(k8sClient.Update(ctx, found)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers[0].Image).To(Equal("example.com/image:v2"))
+	})
+
+	It("should roll out the capabilities of the proxy container when they change", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "capabilities-drift", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		By("Removing a capability of the Deployment by hand")
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		found.Spec.Template.Spec.Containers[0].SecurityContext.Capabilities.Add = []corev1.Capability{"NET_ADMIN"}
+		Expect(k8sClient.Update(ctx, found)).To(Succeed())
+		generation := found.Generation
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers[0].SecurityContext.Capabilities.Add).To(
+			ConsistOf(corev1.Capability("NET_ADMIN"), corev1.Capability("NET_RAW")))
+		Expect(found.Generation).To(BeNumerically(">", generation))
+	})
+
+	It("should apply a custom set of capabilities to the proxy container", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "capabilities", networkingv1.HostproxySpec{
+			HostPort:         10541,
+			ClusterPort:      80,
+			Capabilities:     []corev1.Capability{"NET_RAW", "SYS_PTRACE"},
+			DropCapabilities: []corev1.Capability{"ALL"},
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers[0].SecurityContext.Capabilities).To(Equal(&corev1.Capabilities{
+			Add:  []corev1.Capability{"NET_RAW", "SYS_PTRACE"},
+			Drop: []corev1.Capability{"ALL"},
+		}))
+
+		By("Removing a capability from the spec")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		hostproxy.Spec.Capabilities = []corev1.Capability{"NET_RAW"}
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers[0].SecurityContext.Capabilities.Add).To(
+			Equal([]corev1.Capability{"NET_RAW"}))
+	})
+
+	It("should not update the Deployment of an unchanged Hostproxy", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "spec-hash", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Annotations).To(HaveKey(specHashAnnotation))
+		resourceVersion := found.ResourceVersion
+
+		By("Reconciling the unchanged Hostproxy again")
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.ResourceVersion).To(Equal(resourceVersion))
+	})
+
+	It("should measure the duration of the reconciliations", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "reconcile-duration", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		observations := reconcileDurationObservations()
+
+		By("Reconciling the Hostproxy several times")
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(reconcileDurationObservations()).To(BeNumerically(">=", observations+3))
+	})
+
+	It("should mount the volumes of the spec into the proxy container", func() {
+		volume := corev1.Volume{
+			Name: "config",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "proxy-config"},
+				},
+			},
+		}
+		mount := corev1.VolumeMount{Name: "config", MountPath: "/etc/hostproxy", ReadOnly: true}
+		hostproxy := createTestHostproxy(ctx, namespace, "volumes", networkingv1.HostproxySpec{
+			HostPort:     10541,
+			ClusterPort:  80,
+			Volumes:      []corev1.Volume{volume},
+			VolumeMounts: []corev1.VolumeMount{mount},
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Volumes).To(ContainElement(
+			HaveField("VolumeSource.ConfigMap.LocalObjectReference.Name", "proxy-config")))
+		Expect(found.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(mount))
+	})
+
+	It("should locate the proxy container by its name", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "container-name", networkingv1.HostproxySpec{
+			HostPort:      10541,
+			ClusterPort:   80,
+			ContainerName: "proxy",
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers).To(HaveLen(1))
+		Expect(found.Spec.Template.Spec.Containers[0].Name).To(Equal("proxy"))
+
+		By("Injecting a sidecar before the proxy container")
+		sidecar := corev1.Container{Name: "sidecar", Image: "example.com/sidecar:test"}
+		found.Spec.Template.Spec.Containers = append([]corev1.Container{sidecar}, found.Spec.Template.Spec.Containers...)
+		Expect(k8sClient.Update(ctx, found)).To(Succeed())
+
+		By("Changing the environment of the proxy")
+		hostproxy.Spec.Env = []corev1.EnvVar{{Name: "PROXY_TIMEOUT", Value: "30s"}}
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers).To(HaveLen(2))
+		Expect(found.Spec.Template.Spec.Containers[0].Name).To(Equal(sidecar.Name))
+		Expect(found.Spec.Template.Spec.Containers[1].Env).To(ContainElement(
+			corev1.EnvVar{Name: "PROXY_TIMEOUT", Value: "30s"}))
+	})
+
+	It("should repair a Deployment without containers", func() {
+		// The API server rejects a Deployment without containers, so the malformed
+		// Deployment can only be served by a fake client
+		createService := false
+		hostproxy := &networkingv1.Hostproxy{
+			ObjectMeta: metav1.ObjectMeta{Name: "malformed", Namespace: namespace},
+			Spec: networkingv1.HostproxySpec{
+				HostPort:      10541,
+				ClusterPort:   80,
+				CreateService: &createService,
+			},
+		}
+		replicas := int32(1)
+		malformed := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: hostproxy.Name, Namespace: namespace},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: podtemplate.SelectorLabels(hostproxy.Name)},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(k8sClient.Scheme()).
+			WithObjects(hostproxy, malformed).
+			WithStatusSubresource(hostproxy).
+			Build()
+		fakeReconciler := &HostproxyReconciler{
+			Client:   fakeClient,
+			Scheme:   fakeClient.Scheme(),
+			Recorder: record.NewFakeRecorder(100),
+		}
+
+		for i := 0; i < 3; i++ {
+			Expect(func() {
+				_, err := fakeReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: client.ObjectKeyFromObject(hostproxy),
+				})
+				Expect(err).To(Not(HaveOccurred()))
+			}).NotTo(Panic())
+		}
+
+		found := &appsv1.Deployment{}
+		Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers).To(HaveLen(1))
+		Expect(found.Spec.Template.Spec.Containers[0].Name).To(Equal(podtemplate.DefaultContainerName))
+	})
+
+	It("should recreate a Deployment whose selector has changed", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "selector", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		By("Creating a Deployment with an outdated selector")
+		outdated, err := hostproxyReconciler.deploymentForHostproxy(hostproxy)
+		Expect(err).To(Not(HaveOccurred()))
+		legacyLabels := map[string]string{"app": "legacy-hostproxy"}
+		outdated.Spec.Selector = &metav1.LabelSelector{MatchLabels: legacyLabels}
+		outdated.Spec.Template.Labels = legacyLabels
+		Expect(k8sClient.Create(ctx, outdated)).To(Succeed())
+
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Selector.MatchLabels).To(Equal(podtemplate.SelectorLabels(hostproxy.Name)))
+	})
+
+	It("should keep a Deployment selecting the version of a former image", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "selector-version", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		By("Creating a Deployment selecting the version of the image like the former releases")
+		former, err := hostproxyReconciler.deploymentForHostproxy(hostproxy)
+		Expect(err).To(Not(HaveOccurred()))
+		former.Spec.Selector.MatchLabels["app.kubernetes.io/version"] = "v0"
+		former.Spec.Template.Labels["app.kubernetes.io/version"] = "v0"
+		Expect(k8sClient.Create(ctx, former)).To(Succeed())
+
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.UID).To(Equal(former.UID))
+	})
+
+	It("should report proxy pods failing to pull their image", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "image-pull", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		By("Creating a proxy pod which can't pull its image")
+		pod := createTestPod(ctx, hostproxy, "image-pull-pod")
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  podtemplate.DefaultContainerName,
+			Image: "example.com/image:test",
+			State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{
+					Reason:  "ImagePullBackOff",
+					Message: "Back-off pulling image \"example.com/image:test\"",
+				},
+			},
+		}}
+		Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeDegradedHostproxy)
+		Expect(condition).To(Not(BeNil()))
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(networkingv1.ReasonImageUnavailable))
+		Expect(condition.Message).To(ContainSubstring("example.com/image:test"))
+	})
+
+	It("should run the proxy without any TCP port in raw mode", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "raw-mode", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			RawMode:     true,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		proxy := found.Spec.Template.Spec.Containers[0]
+		Expect(proxy.Env).To(ContainElement(corev1.EnvVar{Name: "RAW_MODE", Value: "1"}))
+		Expect(proxy.ReadinessProbe).To(BeNil())
+
+		svc := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), svc)).To(Succeed())
+		Expect(svc.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+		Expect(svc.Spec.Ports).To(BeEmpty())
+	})
+
+	It("should report the proxy pods restarting too often", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "crash-looping", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		By("Creating proxy pods which keep restarting")
+		for _, name := range []string{"crash-looping-a", "crash-looping-b"} {
+			pod := createTestPod(ctx, hostproxy, name)
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name:         podtemplate.DefaultContainerName,
+				Image:        "example.com/image:test",
+				RestartCount: 4,
+			}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+		}
+
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(hostproxy.Status.RestartCount).To(Equal(int32(8)))
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeDegradedHostproxy)
+		Expect(condition).To(Not(BeNil()))
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(networkingv1.ReasonCrashLooping))
+	})
+
+	It("should run the sidecars of the spec next to the proxy container", func() {
+		sidecar := corev1.Container{Name: "log-shipper", Image: "example.com/log-shipper:test"}
+		hostproxy := createTestHostproxy(ctx, namespace, "sidecars", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			Sidecars:    []corev1.Container{sidecar},
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers).To(HaveLen(2))
+		Expect(found.Spec.Template.Spec.Containers[0].Name).To(Equal(podtemplate.DefaultContainerName))
+		Expect(found.Spec.Template.Spec.Containers[1].Name).To(Equal(sidecar.Name))
+
+		By("Updating the proxy and its sidecars")
+		hostproxy.Spec.LogLevel = "debug"
+		hostproxy.Spec.Sidecars[0].Image = "example.com/log-shipper:v2"
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		proxy := containerByName(found.Spec.Template.Spec.Containers, podtemplate.DefaultContainerName)
+		Expect(proxy).NotTo(BeNil())
+		Expect(proxy.Env).To(ContainElement(corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+		Expect(containerByName(found.Spec.Template.Spec.Containers, sidecar.Name).Image).To(
+			Equal("example.com/log-shipper:v2"))
+
+		By("Removing the sidecars")
+		hostproxy.Spec.Sidecars = nil
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers).To(HaveLen(1))
+		Expect(found.Spec.Template.Spec.Containers[0].Name).To(Equal(podtemplate.DefaultContainerName))
+	})
+
+	It("should apply the seccomp and AppArmor profiles of the spec", func() {
+		localhostProfile := "profiles/hostproxy.json"
+		hostproxy := createTestHostproxy(ctx, namespace, "security-profiles", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			SeccompProfile: &corev1.SeccompProfile{
+				Type:             corev1.SeccompProfileTypeLocalhost,
+				LocalhostProfile: &localhostProfile,
+			},
+			AppArmorProfile: "localhost/hostproxy",
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.SecurityContext.SeccompProfile).To(Equal(hostproxy.Spec.SeccompProfile))
+		Expect(found.Spec.Template.Annotations).To(HaveKeyWithValue(
+			corev1.AppArmorBetaContainerAnnotationKeyPrefix+podtemplate.DefaultContainerName, "localhost/hostproxy"))
+	})
+
+	It("should only lift the seccomp restrictions when the Unconfined profile is requested", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "seccomp-default", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.SecurityContext.SeccompProfile).To(Equal(
+			&corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}))
+
+		By("Requesting the Unconfined profile")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		hostproxy.Spec.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined}
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.SecurityContext.SeccompProfile).To(Equal(
+			&corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined}))
+	})
+
+	It("should apply the minReadySeconds of the spec to the Deployment", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "min-ready-seconds", networkingv1.HostproxySpec{
+			HostPort:        10541,
+			ClusterPort:     80,
+			MinReadySeconds: 5,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.MinReadySeconds).To(Equal(int32(5)))
+
+		By("Changing the minReadySeconds")
+		hostproxy.Spec.MinReadySeconds = 10
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.MinReadySeconds).To(Equal(int32(10)))
+	})
+
+	It("should report the rollouts exceeding their progress deadline", func() {
+		deadline := int32(120)
+		hostproxy := createTestHostproxy(ctx, namespace, "progress-deadline", networkingv1.HostproxySpec{
+			HostPort:                10541,
+			ClusterPort:             80,
+			ProgressDeadlineSeconds: &deadline,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.ProgressDeadlineSeconds).To(Equal(&deadline))
+
+		By("Exceeding the progress deadline of the rollout")
+		found.Status.Conditions = []appsv1.DeploymentCondition{{
+			Type:    appsv1.DeploymentProgressing,
+			Status:  corev1.ConditionFalse,
+			Reason:  "ProgressDeadlineExceeded",
+			Message: `ReplicaSet "progress-deadline-5d8f7c9b6" has timed out progressing.`,
+		}}
+		Expect(k8sClient.Status().Update(ctx, found)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeDegradedHostproxy)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(networkingv1.ReasonProgressDeadlineExceeded))
+		Expect(condition.Message).To(ContainSubstring("has timed out progressing"))
+	})
+
+	It("should block the deletion of the Hostproxy until its children are removed", func() {
+		replicas := int32(2)
+		hostproxy := createTestHostproxy(ctx, namespace, "owner-references", networkingv1.HostproxySpec{
+			HostPort:             10541,
+			ClusterPort:          80,
+			Replicas:             &replicas,
+			CreateServiceAccount: true,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		for _, child := range []client.Object{
+			&appsv1.Deployment{},
+			&corev1.Service{},
+			&corev1.ServiceAccount{},
+			&policyv1.PodDisruptionBudget{},
+		} {
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), child)).To(Succeed())
+			owner := metav1.GetControllerOf(child)
+			Expect(owner).To(Not(BeNil()))
+			Expect(owner.UID).To(Equal(hostproxy.UID))
+			Expect(owner.BlockOwnerDeletion).To(HaveValue(BeTrue()))
+		}
+	})
+
+	It("should run the proxy with a DaemonSet in DaemonSet mode", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "daemonset", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			Mode:        networkingv1.DaemonSetMode,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		ds := &appsv1.DaemonSet{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), ds)).To(Succeed())
+		Expect(metav1.IsControlledBy(ds, hostproxy)).To(BeTrue())
+		Expect(ds.Spec.Template.Spec.Containers).To(HaveLen(1))
+		Expect(ds.Spec.Template.Spec.Containers[0].Name).To(Equal(podtemplate.DefaultContainerName))
+		Expect(ds.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+			corev1.EnvVar{Name: "PORTS", Value: "80:10541"}))
+
+		By("Checking that neither a Deployment nor a Service is created")
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &corev1.Service{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		Expect(meta.IsStatusConditionTrue(hostproxy.Status.Conditions, typeAvailableHostproxy)).To(BeTrue())
+		Expect(hostproxy.Status.OwnedResources).To(ConsistOf("DaemonSet/" + hostproxy.Name))
+	})
+
+	It("should requeue with the configured interval", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "requeue-interval", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		hostproxyReconciler.RequeueInterval = 5 * time.Second
+
+		By("Reconciling the Hostproxy which creates the Deployment")
+		result, err := hostproxyReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(hostproxy),
+		})
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(result.RequeueAfter).To(Equal(5 * time.Second))
+	})
+
+	It("should keep the labels added to the Deployment", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "custom-labels", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		By("Adding a custom label to the Deployment")
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		found.Labels["team"] = "networking"
+		Expect(k8sClient.Update(ctx, found)).To(Succeed())
+
+		By("Changing the spec so that the Deployment is updated")
+		hostproxy.Spec.LogLevel = "debug"
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+			corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+		Expect(found.Labels).To(HaveKeyWithValue("team", "networking"))
+		for key, value := range labelsForHostproxy(hostproxy.Name) {
+			Expect(found.Labels).To(HaveKeyWithValue(key, value))
+		}
+	})
+
+	It("should allocate distinct host ports", func() {
+		first := createTestHostproxy(ctx, namespace, "auto-allocate-first", networkingv1.HostproxySpec{
+			AutoAllocate: true,
+			ClusterPort:  80,
+		})
+		second := createTestHostproxy(ctx, namespace, "auto-allocate-second", networkingv1.HostproxySpec{
+			AutoAllocate: true,
+			ClusterPort:  80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, first)
+		reconcileHostproxy(ctx, hostproxyReconciler, second)
+
+		Expect(first.Status.AllocatedHostPort).To(BeNumerically(">=", defaultHostPortRangeStart))
+		Expect(second.Status.AllocatedHostPort).To(BeNumerically(">=", defaultHostPortRangeStart))
+		Expect(first.Status.AllocatedHostPort).NotTo(Equal(second.Status.AllocatedHostPort))
+
+		By("Passing the allocated port to the proxy")
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(second), found)).To(Succeed())
+		Expect(found.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+			Name:  "PORTS",
+			Value: fmt.Sprintf("80:%d", second.Status.AllocatedHostPort),
+		}))
+	})
+
+	It("should not allocate the host port of a backend", func() {
+		createTestHostproxy(ctx, namespace, "backend-ports", networkingv1.HostproxySpec{
+			ClusterPort: 80,
+			Backends: []networkingv1.Backend{
+				{Name: "blue", HostPort: 40600},
+				{Name: "green", HostPort: 40601},
+			},
+		})
+		hostproxy := createTestHostproxy(ctx, namespace, "auto-allocate-backends", networkingv1.HostproxySpec{
+			AutoAllocate: true,
+			ClusterPort:  80,
+		})
+		hostproxyReconciler.HostPortRangeStart = 40600
+		hostproxyReconciler.HostPortRangeEnd = 40602
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(hostproxy.Status.AllocatedHostPort).To(Equal(int32(40602)))
+	})
+
+	It("should leave the children of a paused Hostproxy untouched", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "paused", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		By("Pausing the Hostproxy and changing its spec")
+		hostproxy.Annotations = map[string]string{networkingv1.PausedAnnotation: "true"}
+		hostproxy.Spec.LogLevel = "debug"
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+
+		By("Scaling the Deployment by hand")
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		replicas := int32(3)
+		found.Spec.Replicas = &replicas
+		Expect(k8sClient.Update(ctx, found)).To(Succeed())
+		resourceVersion := found.ResourceVersion
+
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.ResourceVersion).To(Equal(resourceVersion))
+		Expect(*found.Spec.Replicas).To(Equal(int32(3)))
+		Expect(meta.IsStatusConditionTrue(hostproxy.Status.Conditions, typePausedHostproxy)).To(BeTrue())
+
+		By("Resuming the Hostproxy")
+		hostproxy.Annotations[networkingv1.PausedAnnotation] = "false"
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(*found.Spec.Replicas).To(Equal(int32(1)))
+		Expect(found.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+			corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+		Expect(meta.FindStatusCondition(hostproxy.Status.Conditions, typePausedHostproxy)).To(BeNil())
+	})
+
+	It("should pause the rollouts of the Deployment when requested", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "deployment-paused", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Paused).To(BeFalse())
+
+		By("Pausing the Deployment and changing the spec")
+		hostproxy.Annotations = map[string]string{networkingv1.DeploymentPausedAnnotation: "true"}
+		hostproxy.Spec.LogLevel = "debug"
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Paused).To(BeTrue())
+		Expect(found.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+			corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+
+		By("Resuming the Deployment")
+		delete(hostproxy.Annotations, networkingv1.DeploymentPausedAnnotation)
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Paused).To(BeFalse())
+	})
+
+	It("should check the readiness of the proxy on the cluster port", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "readiness", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 8080,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		probe := found.Spec.Template.Spec.Containers[0].ReadinessProbe
+		Expect(probe).NotTo(BeNil())
+		Expect(probe.TCPSocket).NotTo(BeNil())
+		Expect(probe.TCPSocket.Port).To(Equal(intstr.FromInt32(8080)))
+	})
+
+	It("should check the readiness of the proxy with gRPC when requested", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "grpc-readiness", networkingv1.HostproxySpec{
+			HostPort:            10541,
+			ClusterPort:         50051,
+			HealthCheckProtocol: networkingv1.GRPCHealthCheck,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		probe := found.Spec.Template.Spec.Containers[0].ReadinessProbe
+		Expect(probe).NotTo(BeNil())
+		Expect(probe.TCPSocket).To(BeNil())
+		Expect(probe.GRPC).NotTo(BeNil())
+		Expect(probe.GRPC.Port).To(Equal(int32(50051)))
+	})
+
+	It("should set the user and the group of the proxy pods", func() {
+		user, group, nonRoot := int64(1000), int64(2000), true
+		hostproxy := createTestHostproxy(ctx, namespace, "security-context", networkingv1.HostproxySpec{
+			HostPort:     10541,
+			ClusterPort:  80,
+			RunAsUser:    &user,
+			RunAsNonRoot: &nonRoot,
+			FSGroup:      &group,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		securityContext := found.Spec.Template.Spec.SecurityContext
+		Expect(securityContext.RunAsUser).To(HaveValue(Equal(user)))
+		Expect(securityContext.RunAsNonRoot).To(HaveValue(BeTrue()))
+		Expect(securityContext.FSGroup).To(HaveValue(Equal(group)))
+	})
+
+	It("should drain the proxy pods before scaling down", func() {
+		replicas := int32(2)
+		hostproxy := createTestHostproxy(ctx, namespace, "drain", networkingv1.HostproxySpec{
+			HostPort:         10541,
+			ClusterPort:      80,
+			Replicas:         &replicas,
+			DrainOnScaleDown: true,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		createTestPod(ctx, hostproxy, "drain-a")
+		createTestPod(ctx, hostproxy, "drain-b")
+
+		By("Scaling the Hostproxy down")
+		replicas = 1
+		hostproxy.Spec.Replicas = &replicas
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(*found.Spec.Replicas).To(Equal(int32(2)))
+
+		draining := &corev1.Pod{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "drain-a", Namespace: namespace}, draining)).To(Succeed())
+		Expect(draining.Annotations).To(HaveKeyWithValue(networkingv1.DrainAnnotation, "true"))
+		kept := &corev1.Pod{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "drain-b", Namespace: namespace}, kept)).To(Succeed())
+		Expect(kept.Annotations).NotTo(HaveKey(networkingv1.DrainAnnotation))
+
+		By("Acknowledging the drain")
+		draining.Status.Conditions = append(draining.Status.Conditions, corev1.PodCondition{
+			Type:   networkingv1.DrainedPodCondition,
+			Status: corev1.ConditionTrue,
+		})
+		Expect(k8sClient.Status().Update(ctx, draining)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(*found.Spec.Replicas).To(Equal(int32(1)))
+	})
+
+	It("should report a port conflict when no host port is free", func() {
+		createTestHostproxy(ctx, namespace, "port-taken", networkingv1.HostproxySpec{
+			HostPort:    40500,
+			ClusterPort: 80,
+		})
+		hostproxy := createTestHostproxy(ctx, namespace, "port-conflict", networkingv1.HostproxySpec{
+			AutoAllocate: true,
+			ClusterPort:  80,
+		})
+		hostproxyReconciler.HostPortRangeStart = 40500
+		hostproxyReconciler.HostPortRangeEnd = 40500
+
+		_, err := hostproxyReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(hostproxy),
+		})
+		Expect(err).To(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeAvailableHostproxy)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(networkingv1.ReasonPortConflict))
+	})
+
+	It("should name the Service as requested", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "service-name", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			ServiceName: "backend",
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		service := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "backend", Namespace: namespace}, service)).To(Succeed())
+		Expect(metav1.IsControlledBy(service, hostproxy)).To(BeTrue())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &corev1.Service{})).NotTo(Succeed())
+		Expect(hostproxy.Status.OwnedResources).To(ContainElement("Service/backend"))
+
+		By("Renaming the Service")
+		hostproxy.Spec.ServiceName = "frontend"
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "frontend", Namespace: namespace}, service)).To(Succeed())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "backend", Namespace: namespace}, service)).NotTo(Succeed())
+	})
+
+	It("should append the name suffix to the names of the Deployment and of the Service", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "name-suffix", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			NameSuffix:  "-proxy",
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "name-suffix-proxy", Namespace: namespace}, deployment)).To(Succeed())
+		Expect(metav1.IsControlledBy(deployment, hostproxy)).To(BeTrue())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "name-suffix-proxy", Namespace: namespace}, &corev1.Service{})).To(Succeed())
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(hostproxy.Status.OwnedResources).To(ContainElements("Deployment/name-suffix-proxy", "Service/name-suffix-proxy"))
+
+		By("Scaling the Hostproxy")
+		replicas := int32(2)
+		hostproxy.Spec.Replicas = &replicas
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deployment), deployment)).To(Succeed())
+		Expect(*deployment.Spec.Replicas).To(Equal(int32(2)))
+
+		By("Removing the name suffix")
+		hostproxy.Spec.NameSuffix = ""
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &appsv1.Deployment{})).To(Succeed())
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(deployment), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should manage a Service in another namespace and delete it with the finalizer", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "shared-service", networkingv1.HostproxySpec{
+			HostPort:         10541,
+			ClusterPort:      80,
+			ServiceNamespace: "shared",
+		})
+		pod := createTestPod(ctx, hostproxy, "shared-service-pod")
+		pod.Status.PodIP = "10.1.2.3"
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		key := types.NamespacedName{Name: "shared-service", Namespace: "shared"}
+		service := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, key, service)).To(Succeed())
+		Expect(service.OwnerReferences).To(BeEmpty())
+		Expect(service.Spec.Selector).To(BeEmpty())
+		Expect(service.Labels).To(HaveKeyWithValue(ownerNamespaceLabel, namespace))
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &corev1.Service{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		endpoints := &corev1.Endpoints{}
+		Expect(k8sClient.Get(ctx, key, endpoints)).To(Succeed())
+		Expect(metav1.IsControlledBy(endpoints, service)).To(BeTrue())
+		Expect(endpoints.Subsets).To(HaveLen(1))
+		Expect(endpoints.Subsets[0].Addresses).To(HaveLen(1))
+		Expect(endpoints.Subsets[0].Addresses[0].IP).To(Equal("10.1.2.3"))
+		Expect(endpoints.Subsets[0].Ports).To(ConsistOf(
+			corev1.EndpointPort{Name: "proxy", Port: 80, Protocol: corev1.ProtocolTCP}))
+
+		By("Deleting the Hostproxy")
+		Expect(k8sClient.Delete(ctx, hostproxy)).To(Succeed())
+		_, err = hostproxyReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(hostproxy)})
+		Expect(err).To(Not(HaveOccurred()))
+
+		err = k8sClient.Get(ctx, key, &corev1.Service{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should follow the proxy pods in the Endpoints of a Service in another namespace", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "shared-endpoints", networkingv1.HostproxySpec{
+			HostPort:         10541,
+			ClusterPort:      80,
+			ServiceNamespace: "shared",
+		})
+		pod := createTestPod(ctx, hostproxy, "shared-endpoints-pod")
+		pod.Status.PodIP = "10.1.2.4"
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		key := types.NamespacedName{Name: "shared-endpoints", Namespace: "shared"}
+		endpoints := &corev1.Endpoints{}
+		Expect(k8sClient.Get(ctx, key, endpoints)).To(Succeed())
+		Expect(endpoints.Subsets[0].Addresses).To(ConsistOf(HaveField("IP", "10.1.2.4")))
+
+		By("Replacing the proxy pod with one of another IP")
+		Expect(k8sClient.Delete(ctx, pod, client.GracePeriodSeconds(0))).To(Succeed())
+		pod = createTestPod(ctx, hostproxy, "shared-endpoints-pod-rescheduled")
+		pod.Status.PodIP = "10.1.2.5"
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+		By("Mapping the proxy pod to its Hostproxy")
+		Expect(hostproxyReconciler.hostproxyForProxyPod(ctx, pod)).To(ConsistOf(
+			reconcile.Request{NamespacedName: client.ObjectKeyFromObject(hostproxy)}))
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, key, endpoints)).To(Succeed())
+		Expect(endpoints.Subsets[0].Addresses).To(ConsistOf(HaveField("IP", "10.1.2.5")))
+
+		By("Ignoring the proxy pods of a Hostproxy whose Service is in its own namespace")
+		local := createTestHostproxy(ctx, namespace, "local-endpoints", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		Expect(hostproxyReconciler.hostproxyForProxyPod(ctx, createTestPod(ctx, local, "local-endpoints-pod"))).To(BeEmpty())
+	})
+
+	It("should restrict the access to the proxy pods to the allowed sources", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "network-policy", networkingv1.HostproxySpec{
+			HostPort:       10541,
+			ClusterPort:    80,
+			AllowedSources: []string{"10.0.0.0/8", "team=backend"},
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		policy := &netv1.NetworkPolicy{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), policy)).To(Succeed())
+		Expect(metav1.IsControlledBy(policy, hostproxy)).To(BeTrue())
+		Expect(policy.Spec.PodSelector.MatchLabels).To(Equal(podtemplate.SelectorLabels(hostproxy.Name)))
+		Expect(policy.Spec.Ingress).To(HaveLen(1))
+		ingress := policy.Spec.Ingress[0]
+		Expect(ingress.Ports).To(HaveLen(1))
+		Expect(*ingress.Ports[0].Port).To(Equal(intstr.FromInt32(80)))
+		Expect(ingress.From).To(ConsistOf(
+			netv1.NetworkPolicyPeer{IPBlock: &netv1.IPBlock{CIDR: "10.0.0.0/8"}},
+			netv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "backend"},
+			}},
+		))
+
+		By("Removing the allowed sources")
+		hostproxy.Spec.AllowedSources = nil
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), policy)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should report the port mapping passed to the proxy in the status", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "ports-env", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		Expect(hostproxy.Status.PortsEnv).To(Equal(fmt.Sprintf("%d:%d", hostproxy.Spec.ClusterPort, hostproxy.Spec.HostPort)))
+
+		By("Splitting the Hostproxy between backends")
+		hostproxy.Spec.Backends = []networkingv1.Backend{
+			{Name: "blue", HostPort: 10541},
+			{Name: "green", HostPort: 10542},
+		}
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		Expect(hostproxy.Status.PortsEnv).To(Equal("80:10541,80:10542"))
+	})
+
+	It("should keep the last significant events in the status", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "recent-events", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(hostproxy.Status.RecentEvents).To(HaveLen(1))
+		Expect(hostproxy.Status.RecentEvents[0].Type).To(Equal("Normal"))
+		Expect(hostproxy.Status.RecentEvents[0].Reason).To(Equal(networkingv1.ReasonDeploymentCreated))
+		Expect(hostproxy.Status.RecentEvents[0].Message).To(Equal("Created the Deployment recent-events"))
+
+		By("Scaling the Hostproxy more times than the events kept")
+		for replicas := int32(2); replicas <= maxRecentEvents+2; replicas++ {
+			size := replicas
+			hostproxy.Spec.Replicas = &size
+			Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+			reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+			Expect(len(hostproxy.Status.RecentEvents)).To(BeNumerically("<=", maxRecentEvents))
+		}
+
+		Expect(hostproxy.Status.RecentEvents).To(HaveLen(maxRecentEvents))
+		for _, event := range hostproxy.Status.RecentEvents {
+			Expect(event.Reason).To(Equal(networkingv1.ReasonScaled))
+		}
+		Expect(hostproxy.Status.RecentEvents[maxRecentEvents-1].Message).To(
+			Equal(fmt.Sprintf("Scaled the Deployment recent-events from %d to %d replicas", maxRecentEvents+1, maxRecentEvents+2)))
+		Expect(hostproxy.Status.RecentEvents[0].Message).To(Equal("Scaled the Deployment recent-events from 2 to 3 replicas"))
+
+		By("Repeating the last event")
+		addRecentEvent(hostproxy, "Normal", networkingv1.ReasonScaled,
+			hostproxy.Status.RecentEvents[maxRecentEvents-1].Message)
+		Expect(hostproxy.Status.RecentEvents).To(HaveLen(maxRecentEvents))
+		Expect(hostproxy.Status.RecentEvents[0].Message).To(Equal("Scaled the Deployment recent-events from 2 to 3 replicas"))
+
+		By("Degrading the proxy with a pod which can't be scheduled")
+		pod := createTestPod(ctx, hostproxy, "recent-events-pod")
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type:    corev1.PodScheduled,
+			Status:  corev1.ConditionFalse,
+			Reason:  corev1.PodReasonUnschedulable,
+			Message: "0/3 nodes are available: 3 Insufficient cpu.",
+		}}
+		Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(hostproxy.Status.RecentEvents).To(HaveLen(maxRecentEvents))
+		last := hostproxy.Status.RecentEvents[maxRecentEvents-1]
+		Expect(last.Type).To(Equal("Warning"))
+		Expect(last.Reason).To(Equal(networkingv1.ReasonUnschedulable))
+		Expect(hostproxy.Status.RecentEvents[maxRecentEvents-2].Reason).To(Equal(networkingv1.ReasonScaled))
+	})
+
+	It("should keep the Available condition stable across reconciliations", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "stable-condition", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeAvailableHostproxy)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		expected := *condition
+
+		By("Scaling the Hostproxy and reconciling it again")
+		replicas := int32(2)
+		hostproxy.Spec.Replicas = &replicas
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		condition = meta.FindStatusCondition(hostproxy.Status.Conditions, typeAvailableHostproxy)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Message).To(Equal(expected.Message))
+		Expect(condition.LastTransitionTime).To(Equal(expected.LastTransitionTime))
+	})
+
+	It("should requeue shortly while the proxy pods aren't ready", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "not-ready", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(hostproxy)}
+
+		result, err := hostproxyReconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(result.RequeueAfter).To(Equal(notReadyRequeueInterval))
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		Expect(meta.IsStatusConditionTrue(hostproxy.Status.Conditions, typeProgressingHostproxy)).To(BeTrue())
+
+		By("Reporting the proxy pods as ready")
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		found.Status.Replicas = 1
+		found.Status.ReadyReplicas = 1
+		Expect(k8sClient.Status().Update(ctx, found)).To(Succeed())
+
+		result, err = hostproxyReconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(result).To(Equal(reconcile.Result{}))
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		Expect(meta.IsStatusConditionFalse(hostproxy.Status.Conditions, typeProgressingHostproxy)).To(BeTrue())
+	})
+
+	It("should only set the external traffic policy of the exposed Services", func() {
+		nodePort := createTestHostproxy(ctx, namespace, "node-port", networkingv1.HostproxySpec{
+			HostPort:              10541,
+			ClusterPort:           80,
+			ServiceType:           corev1.ServiceTypeNodePort,
+			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyLocal,
+		})
+		headless := createTestHostproxy(ctx, namespace, "headless", networkingv1.HostproxySpec{
+			HostPort:              10541,
+			ClusterPort:           80,
+			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyLocal,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, nodePort)
+		reconcileHostproxy(ctx, hostproxyReconciler, headless)
+
+		service := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(nodePort), service)).To(Succeed())
+		Expect(service.Spec.Type).To(Equal(corev1.ServiceTypeNodePort))
+		Expect(service.Spec.ExternalTrafficPolicy).To(Equal(corev1.ServiceExternalTrafficPolicyLocal))
+
+		service = &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(headless), service)).To(Succeed())
+		Expect(service.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+		Expect(service.Spec.ExternalTrafficPolicy).To(BeEmpty())
+	})
+
+	It("should create the Service before the Deployment", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "service-first", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(hostproxy)}
+		for i := 0; i < 3; i++ {
+			_, err := hostproxyReconciler.Reconcile(ctx, request)
+			Expect(err).To(Not(HaveOccurred()))
+
+			err = k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &appsv1.Deployment{})
+			if errors.IsNotFound(err) {
+				continue
+			}
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &corev1.Service{})).To(Succeed())
+			return
+		}
+		Fail("the Deployment has not been created")
+	})
+
+	It("should report the node port allocated to the Service", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "allocated-node-port", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			ServiceType: corev1.ServiceTypeNodePort,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		service := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), service)).To(Succeed())
+		Expect(service.Spec.Ports[0].NodePort).NotTo(BeZero())
+		Expect(hostproxy.Status.ServiceName).To(Equal(service.Name))
+		Expect(hostproxy.Status.NodePort).To(Equal(service.Spec.Ports[0].NodePort))
+	})
+
+	It("should clean up the state of the proxy on the nodes before removing the finalizer", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "cleanup", networkingv1.HostproxySpec{
+			HostPort:         10541,
+			ClusterPort:      80,
+			CleanupHostState: true,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		pod := createTestPod(ctx, hostproxy, "cleanup-pod")
+		pod.Spec.NodeName = "node-a"
+		Expect(k8sClient.Update(ctx, pod)).To(Succeed())
+
+		By("Deleting the Hostproxy")
+		Expect(k8sClient.Delete(ctx, hostproxy)).To(Succeed())
+		request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(hostproxy)}
+		_, err := hostproxyReconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		jobs := &batchv1.JobList{}
+		Expect(k8sClient.List(ctx, jobs, client.InNamespace(namespace))).To(Succeed())
+		Expect(jobs.Items).To(HaveLen(1))
+		job := &jobs.Items[0]
+		Expect(job.Spec.Template.Spec.NodeName).To(Equal("node-a"))
+		Expect(metav1.IsControlledBy(job, hostproxy)).To(BeTrue())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		Expect(hostproxy.Finalizers).To(ContainElement(hostproxyFinalizer))
+
+		By("Completing the cleanup Job")
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+		_, err = hostproxyReconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should report the version of the operator which reconciled the Hostproxy", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "reconciled-by", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		hostproxyReconciler.Version = "v1.2.3"
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		Expect(hostproxy.Status.ReconciledBy).To(Equal("v1.2.3"))
+	})
+
+	It("should not add the finalizer when it is disabled", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "no-finalizer", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		hostproxyReconciler.DisableFinalizer = true
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		Expect(hostproxy.Finalizers).NotTo(ContainElement(hostproxyFinalizer))
+
+		By("Deleting the Hostproxy without reconciling it")
+		Expect(k8sClient.Delete(ctx, hostproxy)).To(Succeed())
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should not wait for a cleanup Job beyond its deadline", func() {
+		deadline := int64(60)
+		hostproxy := createTestHostproxy(ctx, namespace, "cleanup-deadline", networkingv1.HostproxySpec{
+			HostPort:               10541,
+			ClusterPort:            80,
+			CleanupHostState:       true,
+			CleanupDeadlineSeconds: &deadline,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+		pod := createTestPod(ctx, hostproxy, "cleanup-deadline-pod")
+		pod.Spec.NodeName = "node-a"
+		Expect(k8sClient.Update(ctx, pod)).To(Succeed())
+
+		By("Deleting the Hostproxy")
+		Expect(k8sClient.Delete(ctx, hostproxy)).To(Succeed())
+		request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(hostproxy)}
+		_, err := hostproxyReconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		jobs := &batchv1.JobList{}
+		Expect(k8sClient.List(ctx, jobs, client.InNamespace(namespace))).To(Succeed())
+		Expect(jobs.Items).To(HaveLen(1))
+		job := &jobs.Items[0]
+		Expect(job.Spec.ActiveDeadlineSeconds).To(Equal(&deadline))
+
+		By("Failing the cleanup Job on its deadline")
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue,
+			Reason: "DeadlineExceeded"}}
+		Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+		_, err = hostproxyReconciler.Reconcile(ctx, request)
+		Expect(err).To(Not(HaveOccurred()))
+
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(hostproxyReconciler.Recorder.(*record.FakeRecorder).Events).To(
+			Receive(ContainSubstring(networkingv1.ReasonCleanupFailed)))
+	})
+
+	It("should not create the children of a Hostproxy without ports", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "no-ports", networkingv1.HostproxySpec{})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeDegradedHostproxy)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(networkingv1.ReasonMissingPorts))
+	})
+
+	It("should let Prometheus scrape the metrics of the proxy", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "metrics-port", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+			MetricsPort: 9090,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.Spec.Template.Annotations).To(HaveKeyWithValue("prometheus.io/scrape", "true"))
+		Expect(found.Spec.Template.Annotations).To(HaveKeyWithValue("prometheus.io/port", "9090"))
+		Expect(found.Spec.Template.Spec.Containers[0].Ports).To(ContainElement(corev1.ContainerPort{
+			Name:          "metrics",
+			ContainerPort: 9090,
+			Protocol:      corev1.ProtocolTCP,
+		}))
+	})
+
+	It("should keep the error details out of the failure conditions", func() {
+		for _, port := range []int32{40500, 40600} {
+			createTestHostproxy(ctx, namespace, fmt.Sprintf("port-taken-%d", port), networkingv1.HostproxySpec{
+				HostPort:    port,
+				ClusterPort: 80,
+			})
+		}
+		hostproxy := createTestHostproxy(ctx, namespace, "stable-failure", networkingv1.HostproxySpec{
+			AutoAllocate: true,
+			ClusterPort:  80,
+		})
+		request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(hostproxy)}
+
+		var messages []string
+		for _, port := range []int32{40500, 40600} {
+			By(fmt.Sprintf("Allocating the host port in the range %d-%d", port, port))
+			hostproxyReconciler.HostPortRangeStart = port
+			hostproxyReconciler.HostPortRangeEnd = port
+			_, err := hostproxyReconciler.Reconcile(ctx, request)
+			Expect(err).To(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+			condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeAvailableHostproxy)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Message).NotTo(ContainSubstring(fmt.Sprint(port)))
+			messages = append(messages, condition.Message)
+		}
+		Expect(messages[0]).To(Equal(messages[1]))
+	})
+
+	It("should log the identity of the Hostproxy during its reconciliation", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "logging", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		var lines []string
+		logger := funcr.New(func(prefix, args string) {
+			lines = append(lines, args)
+		}, funcr.Options{})
+		_, err := hostproxyReconciler.Reconcile(log.IntoContext(ctx, logger), reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(hostproxy),
+		})
+		Expect(err).To(Not(HaveOccurred()))
+
+		Expect(lines).NotTo(BeEmpty())
+		for _, line := range lines {
+			Expect(line).To(ContainSubstring(`"hostproxy"={"name":"logging","namespace":"%s"}`, namespace))
+		}
+	})
+
+	It("should log the slow reconciliations with their slowest call", func() {
+		hostproxy := createTestHostproxy(ctx, namespace, "slow", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+
+		hostproxyReconciler.Client = timedClient{Client: slowServiceClient{Client: k8sClient, latency: 50 * time.Millisecond}}
+		hostproxyReconciler.SlowReconcileThreshold = 20 * time.Millisecond
+		var lines []string
+		logger := funcr.New(func(prefix, args string) {
+			lines = append(lines, args)
+		}, funcr.Options{})
+		_, err := hostproxyReconciler.Reconcile(log.IntoContext(ctx, logger), reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(hostproxy),
+		})
+		Expect(err).To(Not(HaveOccurred()))
+
+		Expect(lines).To(ContainElement(And(
+			ContainSubstring(`"msg"="Slow reconciliation"`),
+			ContainSubstring(`"slow"=true`),
+			ContainSubstring(`"slowestCall"="get Service %s/slow"`, namespace),
+		)))
+		Expect(testutil.ToFloat64(reconcileMaxDuration)).To(BeNumerically(">=", 0.05))
+	})
+
+	It("should cap the backoff of a failing Hostproxy at the configured delay", func() {
+		item := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "failing"}}
+		for _, maxDelay := range []time.Duration{0, 10 * time.Second} {
+			limiter := hostproxyRateLimiter(maxDelay)
+			var delay time.Duration
+			for i := 0; i < 20; i++ {
+				delay = limiter.When(item)
+			}
+			if maxDelay == 0 {
+				Expect(delay).To(Equal(rateLimiterMaxDelay))
+			} else {
+				Expect(delay).To(Equal(maxDelay))
+			}
+		}
+	})
+
+	It("should recreate the Deployment and the Service of a former Hostproxy of the same name", func() {
+		replicas := int32(1)
+		controller := true
+		staleOwner := metav1.OwnerReference{
+			APIVersion: networkingv1.GroupVersion.String(),
+			Kind:       "Hostproxy",
+			Name:       "recreated",
+			UID:        types.UID("stale-uid"),
+			Controller: &controller,
+		}
+		labels := labelsForHostproxy("recreated")
+		dep := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "recreated", Namespace: namespace,
+				OwnerReferences: []metav1.OwnerReference{staleOwner}},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{
+						Name:  "hostproxy",
+						Image: "example.com/image:test",
+					}}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, dep)).To(Succeed())
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "recreated", Namespace: namespace,
+				OwnerReferences: []metav1.OwnerReference{staleOwner}},
+			Spec: corev1.ServiceSpec{
+				Selector: labels,
+				Ports:    []corev1.ServicePort{{Port: 80}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, svc)).To(Succeed())
+
+		hostproxy := createTestHostproxy(ctx, namespace, "recreated", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(found.UID).NotTo(Equal(dep.UID))
+		Expect(metav1.IsControlledBy(found, hostproxy)).To(BeTrue())
+
+		foundService := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), foundService)).To(Succeed())
+		Expect(foundService.UID).NotTo(Equal(svc.UID))
+		Expect(metav1.IsControlledBy(foundService, hostproxy)).To(BeTrue())
+	})
+
+	It("should adopt the Deployment and the Service created before the Hostproxy", func() {
+		replicas := int32(1)
+		labels := map[string]string{"app": "adopted"}
+		dep := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "adopted", Namespace: namespace},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{
+						Name:  "hostproxy",
+						Image: "example.com/image:test",
+					}}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, dep)).To(Succeed())
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "adopted", Namespace: namespace},
+			Spec: corev1.ServiceSpec{
+				Selector: labels,
+				Ports:    []corev1.ServicePort{{Port: 80}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, svc)).To(Succeed())
+
+		hostproxy := createTestHostproxy(ctx, namespace, "adopted", networkingv1.HostproxySpec{
+			HostPort:    10541,
+			ClusterPort: 80,
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), found)).To(Succeed())
+		Expect(metav1.IsControlledBy(found, hostproxy)).To(BeTrue())
+
+		foundService := &corev1.Service{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), foundService)).To(Succeed())
+		Expect(metav1.IsControlledBy(foundService, hostproxy)).To(BeTrue())
+	})
+
+	It("should handle the children of the backends like the ones of a single host", func() {
+		replicas := int32(2)
+		controller := true
+		staleOwner := metav1.OwnerReference{
+			APIVersion: networkingv1.GroupVersion.String(),
+			Kind:       "Hostproxy",
+			Name:       "backend-children",
+			UID:        types.UID("stale-uid"),
+			Controller: &controller,
+		}
+		labels := labelsForHostproxy("backend-children")
+		labels[backendLabel] = "blue"
+		dep := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "backend-children-blue", Namespace: namespace,
+				OwnerReferences: []metav1.OwnerReference{staleOwner}},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{
+						Name:  "hostproxy",
+						Image: "example.com/image:test",
+					}}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, dep)).To(Succeed())
+		minAvailable := intstr.FromInt32(1)
+		pdb := &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "backend-children", Namespace: namespace},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				MinAvailable: &minAvailable,
+				Selector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, pdb)).To(Succeed())
+
+		hostproxy := createTestHostproxy(ctx, namespace, "backend-children", networkingv1.HostproxySpec{
+			ClusterPort: 80,
+			Replicas:    &replicas,
+			Backends:    []networkingv1.Backend{{Name: "blue", HostPort: 10541}},
+		})
+		reconcileHostproxy(ctx, hostproxyReconciler, hostproxy)
+
+		found := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(dep), found)).To(Succeed())
+		Expect(found.UID).NotTo(Equal(dep.UID))
+		Expect(metav1.IsControlledBy(found, hostproxy)).To(BeTrue())
+
+		foundPDB := &policyv1.PodDisruptionBudget{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(pdb), foundPDB)).To(Succeed())
+		Expect(metav1.IsControlledBy(foundPDB, hostproxy)).To(BeTrue())
+		Expect(foundPDB.Spec.Selector.MatchLabels).To(Equal(podtemplate.SelectorLabels(hostproxy.Name)))
+
+		By("Allowing an invalid source")
+		events := hostproxyReconciler.Recorder.(*record.FakeRecorder).Events
+		for len(events) > 0 {
+			<-events
+		}
+		hostproxy.Spec.AllowedSources = []string{"team in ("}
+		Expect(k8sClient.Update(ctx, hostproxy)).To(Succeed())
+		_, err := hostproxyReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(hostproxy),
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(events).To(Receive(HavePrefix("Warning " + networkingv1.ReasonNetworkPolicyFailed)))
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+		condition := meta.FindStatusCondition(hostproxy.Status.Conditions, typeAvailableHostproxy)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(networkingv1.ReasonNetworkPolicyFailed))
+	})
+})
+
+// createTestNamespace creates a Namespace with a generated name so that each test
+// gets a clean one, since namespaces are never removed by envtest.
+func createTestNamespace(ctx context.Context) string {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "hostproxy-test-",
+		},
+	}
+	Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+	return namespace.Name
+}
+
+// createTestHostproxy creates a Hostproxy with the given spec and returns it as stored on the cluster.
+func createTestHostproxy(ctx context.Context, namespace, name string,
+	spec networkingv1.HostproxySpec) *networkingv1.Hostproxy {
+	hostproxy := &networkingv1.Hostproxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: spec,
+	}
+	Expect(k8sClient.Create(ctx, hostproxy)).To(Succeed())
+	Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+	return hostproxy
+}
+
+// reconcileHostproxy runs the reconciliation of the given Hostproxy as many times as
+// needed by the controller to create all the child resources, then refreshes it.
+func reconcileHostproxy(ctx context.Context, r *HostproxyReconciler, hostproxy *networkingv1.Hostproxy) {
+	for i := 0; i < 3; i++ {
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(hostproxy),
+		})
+		Expect(err).To(Not(HaveOccurred()))
+	}
+	Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(hostproxy), hostproxy)).To(Succeed())
+}
+
+// createTestPod creates a pod carrying the labels of the proxy pods of the given Hostproxy
+func createTestPod(ctx context.Context, hostproxy *networkingv1.Hostproxy, name string) *corev1.Pod {
+	controller := true
+	labels := labelsForHostproxy(hostproxy.Name)
+	labels[appsv1.DefaultDeploymentUniqueLabelKey] = "5d8f7c9b6"
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: hostproxy.Namespace,
+			Labels:    labels,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "ReplicaSet",
+				Name:       deploymentNameForHostproxy(hostproxy) + "-5d8f7c9b6",
+				UID:        types.UID(hostproxy.Name + "-replicaset"),
+				Controller: &controller,
+			}},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "hostproxy",
+				Image: "example.com/image:test",
+			}},
+		},
+	}
+	Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+	return pod
+}
+
+// slowServiceClient delays the reads of the Services, as a slow API server would
+type slowServiceClient struct {
+	client.Client
+	latency time.Duration
+}
+
+func (c slowServiceClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if _, ok := obj.(*corev1.Service); ok {
+		time.Sleep(c.latency)
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+// conflictingStatusClient fails the updates of the status with a conflict, as if the objects
+// had been modified concurrently
+type conflictingStatusClient struct {
+	client.Client
+}
+
+func (c conflictingStatusClient) Status() client.SubResourceWriter {
+	return conflictingStatusWriter{SubResourceWriter: c.Client.Status()}
+}
+
+type conflictingStatusWriter struct {
+	client.SubResourceWriter
+}
+
+func (w conflictingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	return errors.NewConflict(networkingv1.GroupVersion.WithResource("hostproxies").GroupResource(),
+		obj.GetName(), fmt.Errorf("the object has been modified"))
+}
+
+// conflictingDeploymentClient fails the updates of the Deployments with a conflict, as if they
+// had been modified concurrently
+type conflictingDeploymentClient struct {
+	client.Client
+}
+
+func (c conflictingDeploymentClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if _, ok := obj.(*appsv1.Deployment); ok {
+		return errors.NewConflict(appsv1.SchemeGroupVersion.WithResource("deployments").GroupResource(),
+			obj.GetName(), fmt.Errorf("the object has been modified"))
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// terminatingNamespaceClient refuses the creation of objects, as the API server does in a
+// terminating namespace
+type terminatingNamespaceClient struct {
+	client.Client
+}
+
+func (c terminatingNamespaceClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return namespaceTerminatingError(obj)
+}
+
+func (c terminatingNamespaceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() == types.ApplyPatchType {
+		return namespaceTerminatingError(obj)
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func namespaceTerminatingError(obj client.Object) error {
+	err := errors.NewForbidden(schema.GroupResource{}, obj.GetName(),
+		fmt.Errorf("unable to create new content in namespace %s because it is being terminated", obj.GetNamespace()))
+	err.ErrStatus.Details.Causes = append(err.ErrStatus.Details.Causes, metav1.StatusCause{
+		Type:    corev1.NamespaceTerminatingCause,
+		Message: "namespace " + obj.GetNamespace() + " is being terminated",
+		Field:   "metadata.namespace",
+	})
+	return err
+}
+
+// reconcileDurationObservations returns the number of reconciliations observed by the
+// reconcile duration histogram, whatever their result.
+func reconcileDurationObservations() uint64 {
+	var count uint64
+	for _, result := range []string{reconcileResultSuccess, reconcileResultError, reconcileResultRequeue} {
+		metric := &dto.Metric{}
+		Expect(reconcileDuration.WithLabelValues(result).(prometheus.Histogram).Write(metric)).To(Succeed())
+		count += metric.GetHistogram().GetSampleCount()
+	}
+	return count
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Results of a reconciliation reported by the metrics
+const (
+	reconcileResultSuccess = "success"
+	reconcileResultError   = "error"
+	reconcileResultRequeue = "requeue"
+)
+
+// reconcileDuration measures the wall time of the reconciliations of the Hostproxies
+var reconcileDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "hostproxy_reconcile_duration_seconds",
+		Help:    "Duration of the reconciliations of the Hostproxies, by result",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{"result"},
+)
+
+// reconcileMaxDuration reports the longest reconciliation of the Hostproxies since the start of
+// the controller, which the buckets of the histogram can't tell
+var reconcileMaxDuration = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "hostproxy_reconcile_max_duration_seconds",
+		Help: "Duration of the longest reconciliation of the Hostproxies",
+	},
+)
+
+// maxReconcileDuration is the value of reconcileMaxDuration, which is only raised
+var maxReconcileDuration struct {
+	sync.Mutex
+	seconds float64
+}
+
+func init() {
+	// Register the custom metrics with the global registry served by the manager
+	metrics.Registry.MustRegister(reconcileDuration, reconcileMaxDuration)
+}
+
+// observeReconcileDuration records the duration of a reconciliation
+func observeReconcileDuration(elapsed time.Duration, result ctrl.Result, err error) {
+	outcome := reconcileResultSuccess
+	if err != nil {
+		outcome = reconcileResultError
+	} else if result.Requeue || result.RequeueAfter > 0 {
+		outcome = reconcileResultRequeue
+	}
+	reconcileDuration.WithLabelValues(outcome).Observe(elapsed.Seconds())
+
+	maxReconcileDuration.Lock()
+	defer maxReconcileDuration.Unlock()
+	if elapsed.Seconds() > maxReconcileDuration.seconds {
+		maxReconcileDuration.seconds = elapsed.Seconds()
+		reconcileMaxDuration.Set(maxReconcileDuration.seconds)
+	}
+}
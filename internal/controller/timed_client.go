@@ -0,0 +1,125 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// apiCallTracker records the slowest call to the API server made during a reconciliation
+type apiCallTracker struct {
+	mu       sync.Mutex
+	call     string
+	duration time.Duration
+}
+
+type apiCallTrackerKey struct{}
+
+// withAPICallTracker returns a context in which the calls of a timedClient are recorded by tracker
+func withAPICallTracker(ctx context.Context, tracker *apiCallTracker) context.Context {
+	return context.WithValue(ctx, apiCallTrackerKey{}, tracker)
+}
+
+// trackAPICall records a call which started at start in the tracker of the context, if any
+func trackAPICall(ctx context.Context, call string, start time.Time) {
+	tracker, ok := ctx.Value(apiCallTrackerKey{}).(*apiCallTracker)
+	if !ok {
+		return
+	}
+	duration := time.Since(start)
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if duration > tracker.duration {
+		tracker.call, tracker.duration = call, duration
+	}
+}
+
+// describeCall returns a description of a call like "get Deployment default/name"
+func describeCall(verb string, obj interface{}, key client.ObjectKey) string {
+	call := verb + " " + reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+	if key.Name != "" {
+		call += " " + key.String()
+	}
+	return call
+}
+
+// slowest returns the slowest call recorded by the tracker and its duration
+func (t *apiCallTracker) slowest() (string, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.call, t.duration
+}
+
+// timedClient times the calls made to the API server, so that a slow reconciliation can
+// point to the call which made it slow
+type timedClient struct {
+	client.Client
+}
+
+func (c timedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	defer trackAPICall(ctx, describeCall("get", obj, key), time.Now())
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c timedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	defer trackAPICall(ctx, describeCall("list", list, client.ObjectKey{}), time.Now())
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c timedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	defer trackAPICall(ctx, describeCall("create", obj, client.ObjectKeyFromObject(obj)), time.Now())
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c timedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	defer trackAPICall(ctx, describeCall("update", obj, client.ObjectKeyFromObject(obj)), time.Now())
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c timedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	defer trackAPICall(ctx, describeCall("patch", obj, client.ObjectKeyFromObject(obj)), time.Now())
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c timedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	defer trackAPICall(ctx, describeCall("delete", obj, client.ObjectKeyFromObject(obj)), time.Now())
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c timedClient) Status() client.SubResourceWriter {
+	return timedStatusWriter{SubResourceWriter: c.Client.Status()}
+}
+
+type timedStatusWriter struct {
+	client.SubResourceWriter
+}
+
+func (w timedStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	defer trackAPICall(ctx, describeCall("update status of", obj, client.ObjectKeyFromObject(obj)), time.Now())
+	return w.SubResourceWriter.Update(ctx, obj, opts...)
+}
+
+func (w timedStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	defer trackAPICall(ctx, describeCall("patch status of", obj, client.ObjectKeyFromObject(obj)), time.Now())
+	return w.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+}
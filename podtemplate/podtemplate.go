@@ -0,0 +1,477 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package podtemplate builds the template of the proxy pods of a Hostproxy, so that other tools
+// can run the same proxy pods as the controller.
+package podtemplate
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	networkingv1 "github.com/raw1z/hostproxy/api/v1"
+)
+
+// Annotations of the proxy pods which let Prometheus scrape the metrics of the proxy
+const (
+	PrometheusScrapeAnnotation = "prometheus.io/scrape"
+	PrometheusPortAnnotation   = "prometheus.io/port"
+)
+
+// Annotations recording on the pod template the checksums of the configuration and of the
+// credentials of the proxy, so that an update of the ConfigMap or of the Secret changes the template
+// and rolls out the proxy pods
+const (
+	ConfigChecksumAnnotation      = "networking.raw1z.fr/config-checksum"
+	CredentialsChecksumAnnotation = "networking.raw1z.fr/credentials-checksum"
+)
+
+// SidecarsAnnotation records on the pod template the names of the sidecars added from the spec,
+// so that the ones removed from the spec can be told apart from the containers injected by others
+const SidecarsAnnotation = "networking.raw1z.fr/sidecars"
+
+// defaultLogLevel is the verbosity of the proxy when none is set in the spec
+const defaultLogLevel = "info"
+
+// defaultEnvVarName is the variable passing the port mapping to the proxy when none is set in the spec
+const defaultEnvVarName = "PORTS"
+
+// DefaultContainerName is the name of the proxy container when none is set in the spec
+const DefaultContainerName = "hostproxy"
+
+// Definitions of the startup probe derived from the liveness probe, which gives
+// the proxy up to 5 minutes to initialize
+const (
+	startupProbePeriodSeconds    = 10
+	startupProbeFailureThreshold = 30
+)
+
+// ForHostproxy returns the template of the proxy pods of a Hostproxy running the given
+// image, which is shared by the Deployment and the DaemonSet modes. The pods are labelled with the
+// labels selected by the workloads of the Hostproxy.
+func ForHostproxy(hostproxy *networkingv1.Hostproxy, image string) (corev1.PodTemplateSpec, error) {
+	env, err := envForHostproxy(hostproxy)
+	if err != nil {
+		return corev1.PodTemplateSpec{}, err
+	}
+
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: Labels(hostproxy.Name, image),
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName:           ServiceAccountName(hostproxy),
+			AutomountServiceAccountToken: automountServiceAccountTokenForHostproxy(hostproxy),
+			TopologySpreadConstraints:    topologySpreadConstraintsForHostproxy(hostproxy),
+			Affinity:                     affinityForHostproxy(hostproxy),
+			NodeSelector:                 hostproxy.Spec.NodeSelector,
+			ReadinessGates:               hostproxy.Spec.ReadinessGates,
+			Volumes:                      hostproxy.Spec.Volumes,
+			RestartPolicy:                hostproxy.Spec.RestartPolicy,
+			SecurityContext: &corev1.PodSecurityContext{
+				SeccompProfile: seccompProfileForHostproxy(hostproxy),
+				RunAsUser:      hostproxy.Spec.RunAsUser,
+				RunAsNonRoot:   hostproxy.Spec.RunAsNonRoot,
+				FSGroup:        hostproxy.Spec.FSGroup,
+			},
+			Containers: []corev1.Container{{
+				Image:           image,
+				Name:            containerNameForHostproxy(hostproxy),
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				SecurityContext: &corev1.SecurityContext{
+					Capabilities: capabilitiesForHostproxy(hostproxy),
+				},
+				Env:                      env,
+				VolumeMounts:             hostproxy.Spec.VolumeMounts,
+				LivenessProbe:            hostproxy.Spec.LivenessProbe,
+				ReadinessProbe:           readinessProbeForHostproxy(hostproxy),
+				StartupProbe:             startupProbeForHostproxy(hostproxy),
+				TerminationMessagePolicy: hostproxy.Spec.TerminationMessagePolicy,
+			}},
+		},
+	}
+
+	// The pod needs to resolve cluster names even if it lives in the network namespace of the host,
+	// unless another DNS policy is requested
+	if hostproxy.Spec.HostNetwork {
+		template.Spec.HostNetwork = true
+		template.Spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
+	}
+	if hostproxy.Spec.DNSPolicy != "" {
+		template.Spec.DNSPolicy = hostproxy.Spec.DNSPolicy
+	}
+	template.Spec.DNSConfig = hostproxy.Spec.DNSConfig
+
+	// The sidecars come after the proxy container
+	if len(hostproxy.Spec.Sidecars) > 0 {
+		names := make([]string, 0, len(hostproxy.Spec.Sidecars))
+		for _, sidecar := range hostproxy.Spec.Sidecars {
+			template.Spec.Containers = append(template.Spec.Containers, sidecar)
+			names = append(names, sidecar.Name)
+		}
+		metav1.SetMetaDataAnnotation(&template.ObjectMeta, SidecarsAnnotation, strings.Join(names, ","))
+	}
+
+	// The AppArmor profile of a container is set with an annotation of the pod
+	if hostproxy.Spec.AppArmorProfile != "" {
+		metav1.SetMetaDataAnnotation(&template.ObjectMeta, appArmorAnnotationForHostproxy(hostproxy),
+			hostproxy.Spec.AppArmorProfile)
+	}
+
+	// The TUN device of the node is passed to the proxy with a hostPath volume
+	if hostproxy.Spec.DeviceTun {
+		deviceType := corev1.HostPathCharDev
+		template.Spec.Volumes = append(append([]corev1.Volume{}, template.Spec.Volumes...), corev1.Volume{
+			Name: networkingv1.DeviceTunVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: networkingv1.DeviceTunPath, Type: &deviceType},
+			},
+		})
+		template.Spec.Containers[0].VolumeMounts = append(append([]corev1.VolumeMount{},
+			template.Spec.Containers[0].VolumeMounts...), corev1.VolumeMount{
+			Name:      networkingv1.DeviceTunVolumeName,
+			MountPath: networkingv1.DeviceTunPath,
+		})
+	}
+
+	// The configuration files of the proxy are mounted from its ConfigMap
+	if hostproxy.Spec.ConfigMapRef != nil {
+		template.Spec.Volumes = append(append([]corev1.Volume{}, template.Spec.Volumes...), corev1.Volume{
+			Name: networkingv1.ConfigVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: *hostproxy.Spec.ConfigMapRef},
+			},
+		})
+		template.Spec.Containers[0].VolumeMounts = append(append([]corev1.VolumeMount{},
+			template.Spec.Containers[0].VolumeMounts...), corev1.VolumeMount{
+			Name:      networkingv1.ConfigVolumeName,
+			MountPath: networkingv1.ConfigMountPath,
+			ReadOnly:  true,
+		})
+		if hostproxy.Status.ConfigChecksum != "" {
+			metav1.SetMetaDataAnnotation(&template.ObjectMeta, ConfigChecksumAnnotation,
+				hostproxy.Status.ConfigChecksum)
+		}
+	}
+
+	// The credentials of the proxy are mounted from its Secret
+	if hostproxy.Spec.SecretRef != nil {
+		template.Spec.Volumes = append(append([]corev1.Volume{}, template.Spec.Volumes...), corev1.Volume{
+			Name: networkingv1.CredentialsVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: hostproxy.Spec.SecretRef.Name},
+			},
+		})
+		template.Spec.Containers[0].VolumeMounts = append(append([]corev1.VolumeMount{},
+			template.Spec.Containers[0].VolumeMounts...), corev1.VolumeMount{
+			Name:      networkingv1.CredentialsVolumeName,
+			MountPath: networkingv1.CredentialsMountPath,
+			ReadOnly:  true,
+		})
+		if hostproxy.Status.CredentialsChecksum != "" {
+			metav1.SetMetaDataAnnotation(&template.ObjectMeta, CredentialsChecksumAnnotation,
+				hostproxy.Status.CredentialsChecksum)
+		}
+	}
+
+	// Let Kubernetes reserve the host port on the node running the proxy
+	if hostproxy.Spec.UseContainerHostPort && !hostproxy.Spec.RawMode {
+		template.Spec.Containers[0].Ports = append(template.Spec.Containers[0].Ports, corev1.ContainerPort{
+			ContainerPort: hostproxy.Spec.ClusterPort,
+			HostPort:      HostPort(hostproxy),
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+
+	// Let Prometheus discover the metrics exposed by the proxy
+	if hostproxy.Spec.MetricsPort != 0 {
+		metav1.SetMetaDataAnnotation(&template.ObjectMeta, PrometheusScrapeAnnotation, "true")
+		metav1.SetMetaDataAnnotation(&template.ObjectMeta, PrometheusPortAnnotation,
+			strconv.Itoa(int(hostproxy.Spec.MetricsPort)))
+		template.Spec.Containers[0].Ports = append(template.Spec.Containers[0].Ports, corev1.ContainerPort{
+			Name:          "metrics",
+			ContainerPort: hostproxy.Spec.MetricsPort,
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+
+	return template, nil
+}
+
+// envForHostproxy returns the environment variables of the proxy container.
+// The variables managed by the controller take precedence over the ones set in the spec.
+func envForHostproxy(hostproxy *networkingv1.Hostproxy) ([]corev1.EnvVar, error) {
+	logLevel := hostproxy.Spec.LogLevel
+	if logLevel == "" {
+		logLevel = defaultLogLevel
+	}
+
+	ports, err := Ports(hostproxy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render the port mapping: %w", err)
+	}
+
+	env := []corev1.EnvVar{
+		{
+			Name:  envVarNameForHostproxy(hostproxy),
+			Value: ports,
+		},
+		{
+			Name:  "LOG_LEVEL",
+			Value: logLevel,
+		},
+	}
+	if hostproxy.Spec.RawMode {
+		env = append(env, corev1.EnvVar{Name: "RAW_MODE", Value: "1"})
+	}
+	if hostproxy.Spec.ConfigMapRef != nil {
+		env = append(env, corev1.EnvVar{Name: "CONFIG_PATH", Value: networkingv1.ConfigMountPath})
+	}
+	if hostproxy.Spec.SecretRef != nil {
+		env = append(env, corev1.EnvVar{Name: "CREDS_PATH", Value: networkingv1.CredentialsMountPath})
+	}
+
+	managed := make(map[string]bool, len(env))
+	for _, e := range env {
+		managed[e.Name] = true
+	}
+	for _, e := range hostproxy.Spec.Env {
+		if !managed[e.Name] {
+			env = append(env, e)
+		}
+	}
+	return env, nil
+}
+
+// Ports returns the port mapping passed to the proxy, rendered with the format of the spec.
+// Its default format is clusterPort:hostPort, or clusterPort:hostAddress:hostPort when the address
+// of the host is set, in which case IPv6 addresses are enclosed in brackets.
+func Ports(hostproxy *networkingv1.Hostproxy) (string, error) {
+	hostPort := HostPort(hostproxy)
+	address := strings.TrimSuffix(strings.TrimPrefix(hostproxy.Spec.HostAddress, "["), "]")
+	if hostproxy.Spec.EnvVarFormat != "" {
+		tmpl, err := template.New("envVarFormat").Parse(hostproxy.Spec.EnvVarFormat)
+		if err != nil {
+			return "", err
+		}
+		ports := &strings.Builder{}
+		err = tmpl.Execute(ports, networkingv1.PortMapping{
+			ClusterPort: hostproxy.Spec.ClusterPort,
+			HostPort:    hostPort,
+			HostAddress: address,
+		})
+		return ports.String(), err
+	}
+
+	if hostproxy.Spec.HostAddress == "" {
+		return fmt.Sprintf("%d:%d", hostproxy.Spec.ClusterPort, hostPort), nil
+	}
+	return fmt.Sprintf("%d:%s", hostproxy.Spec.ClusterPort,
+		net.JoinHostPort(address, strconv.Itoa(int(hostPort)))), nil
+}
+
+// envVarNameForHostproxy returns the name of the variable passing the port mapping to the proxy
+func envVarNameForHostproxy(hostproxy *networkingv1.Hostproxy) string {
+	if hostproxy.Spec.EnvVarName == "" {
+		return defaultEnvVarName
+	}
+	return hostproxy.Spec.EnvVarName
+}
+
+// HostPort returns the port of the host which is proxied, which is the one
+// allocated by the controller when the spec doesn't set it
+func HostPort(hostproxy *networkingv1.Hostproxy) int32 {
+	if hostproxy.Spec.HostPort == 0 {
+		return hostproxy.Status.AllocatedHostPort
+	}
+	return hostproxy.Spec.HostPort
+}
+
+// capabilitiesForHostproxy returns the capabilities of the proxy container, which are the ones
+// needed to set up the forwarding unless others are set in the spec
+func capabilitiesForHostproxy(hostproxy *networkingv1.Hostproxy) *corev1.Capabilities {
+	capabilities := &corev1.Capabilities{
+		Add:  hostproxy.Spec.Capabilities,
+		Drop: hostproxy.Spec.DropCapabilities,
+	}
+	if len(capabilities.Add) == 0 {
+		capabilities.Add = []corev1.Capability{"NET_ADMIN", "NET_RAW"}
+	}
+	return capabilities
+}
+
+// containerNameForHostproxy returns the name of the proxy container
+func containerNameForHostproxy(hostproxy *networkingv1.Hostproxy) string {
+	if hostproxy.Spec.ContainerName == "" {
+		return DefaultContainerName
+	}
+	return hostproxy.Spec.ContainerName
+}
+
+// seccompProfileForHostproxy returns the seccomp profile of the proxy pods, RuntimeDefault
+// unless set in the spec
+func seccompProfileForHostproxy(hostproxy *networkingv1.Hostproxy) *corev1.SeccompProfile {
+	if hostproxy.Spec.SeccompProfile != nil {
+		return hostproxy.Spec.SeccompProfile
+	}
+	return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+}
+
+// appArmorAnnotationForHostproxy returns the pod annotation setting the AppArmor profile of the proxy container
+func appArmorAnnotationForHostproxy(hostproxy *networkingv1.Hostproxy) string {
+	return corev1.AppArmorBetaContainerAnnotationKeyPrefix + containerNameForHostproxy(hostproxy)
+}
+
+// ServiceAccountName returns the name of the ServiceAccount running the proxy pods.
+// An empty name means that the default ServiceAccount of the namespace is used.
+func ServiceAccountName(hostproxy *networkingv1.Hostproxy) string {
+	if hostproxy.Spec.ServiceAccountName == "" && hostproxy.Spec.CreateServiceAccount {
+		return hostproxy.Name
+	}
+	return hostproxy.Spec.ServiceAccountName
+}
+
+// automountServiceAccountTokenForHostproxy returns whether the token of the ServiceAccount is
+// mounted in the proxy pods, which it isn't unless requested
+func automountServiceAccountTokenForHostproxy(hostproxy *networkingv1.Hostproxy) *bool {
+	automount := hostproxy.Spec.AutomountServiceAccountToken != nil && *hostproxy.Spec.AutomountServiceAccountToken
+	return &automount
+}
+
+// topologySpreadConstraintsForHostproxy returns the topology spread constraints of the proxy pods.
+// Unless set in the spec, the replicas are spread across the zones of the cluster.
+func topologySpreadConstraintsForHostproxy(hostproxy *networkingv1.Hostproxy) []corev1.TopologySpreadConstraint {
+	if len(hostproxy.Spec.TopologySpreadConstraints) > 0 {
+		return hostproxy.Spec.TopologySpreadConstraints
+	}
+	if Replicas(hostproxy) <= 1 {
+		return nil
+	}
+	return []corev1.TopologySpreadConstraint{{
+		MaxSkew:           1,
+		TopologyKey:       corev1.LabelTopologyZone,
+		WhenUnsatisfiable: corev1.ScheduleAnyway,
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: SelectorLabels(hostproxy.Name),
+		},
+	}}
+}
+
+// affinityForHostproxy returns the affinity co-locating the proxy pods with the pods serving
+// the host port, if any
+func affinityForHostproxy(hostproxy *networkingv1.Hostproxy) *corev1.Affinity {
+	if hostproxy.Spec.TargetPodSelector == nil {
+		return nil
+	}
+	return &corev1.Affinity{
+		PodAffinity: &corev1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+				LabelSelector: hostproxy.Spec.TargetPodSelector,
+				TopologyKey:   corev1.LabelHostname,
+			}},
+		},
+	}
+}
+
+// Replicas returns the number of proxy pods requested by the Hostproxy
+func Replicas(hostproxy *networkingv1.Hostproxy) int32 {
+	if hostproxy.Spec.Replicas == nil {
+		return 1
+	}
+	return *hostproxy.Spec.Replicas
+}
+
+// startupProbeForHostproxy returns the startup probe of the proxy container.
+// Unless set in the spec, it is derived from the liveness probe, so that the proxy isn't
+// killed while it initializes.
+func startupProbeForHostproxy(hostproxy *networkingv1.Hostproxy) *corev1.Probe {
+	if hostproxy.Spec.StartupProbe != nil {
+		return hostproxy.Spec.StartupProbe
+	}
+	if hostproxy.Spec.LivenessProbe == nil {
+		return nil
+	}
+	return &corev1.Probe{
+		ProbeHandler:     *hostproxy.Spec.LivenessProbe.ProbeHandler.DeepCopy(),
+		TimeoutSeconds:   hostproxy.Spec.LivenessProbe.TimeoutSeconds,
+		PeriodSeconds:    startupProbePeriodSeconds,
+		FailureThreshold: startupProbeFailureThreshold,
+	}
+}
+
+// readinessProbeForHostproxy returns the readiness probe of the proxy container, which checks
+// that the proxy accepts the connections on the cluster port it forwards, or that the backend
+// answers the gRPC health checks when requested. A Hostproxy maps a single port, so a single probe
+// is enough to check it.
+func readinessProbeForHostproxy(hostproxy *networkingv1.Hostproxy) *corev1.Probe {
+	// In raw mode, the proxy doesn't accept any connection on the cluster port
+	if hostproxy.Spec.RawMode {
+		return nil
+	}
+	if hostproxy.Spec.HealthCheckProtocol == networkingv1.GRPCHealthCheck {
+		return &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				GRPC: &corev1.GRPCAction{
+					Port: hostproxy.Spec.ClusterPort,
+				},
+			},
+		}
+	}
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{
+				Port: intstr.FromInt32(hostproxy.Spec.ClusterPort),
+			},
+		},
+	}
+}
+
+// Labels returns the labels of the resources of a Hostproxy running the given image, which add
+// the version of the image to the labels selecting them
+// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/common-labels/
+func Labels(name, image string) map[string]string {
+	labels := SelectorLabels(name)
+	labels["app.kubernetes.io/version"] = ImageVersion(image)
+	return labels
+}
+
+// SelectorLabels returns the labels for selecting the resources. They don't include
+// the version, so that the immutable selectors of the workloads survive an upgrade of the image.
+func SelectorLabels(name string) map[string]string {
+	return map[string]string{"app.kubernetes.io/name": "Hostproxy",
+		"app.kubernetes.io/instance":   name,
+		"app.kubernetes.io/part-of":    "hostproxy",
+		"app.kubernetes.io/created-by": "controller-manager",
+	}
+}
+
+// ImageVersion returns the tag of the given image reference, or an empty string
+// when the image isn't tagged. Digests and registry ports are not mistaken for a tag.
+func ImageVersion(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtemplate_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkingv1 "github.com/raw1z/hostproxy/api/v1"
+	"github.com/raw1z/hostproxy/podtemplate"
+)
+
+var _ = Describe("Pod template", func() {
+	It("should build the pod template of a Hostproxy with the given image", func() {
+		hostproxy := &networkingv1.Hostproxy{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod-template", Namespace: "default"},
+			Spec: networkingv1.HostproxySpec{
+				HostPort:     10541,
+				ClusterPort:  80,
+				NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+			},
+		}
+		template, err := podtemplate.ForHostproxy(hostproxy, "example.com/proxy:v3")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(template.Labels).To(Equal(podtemplate.Labels(hostproxy.Name, "example.com/proxy:v3")))
+		Expect(template.Labels).To(HaveKeyWithValue("app.kubernetes.io/version", "v3"))
+		Expect(template.Spec.NodeSelector).To(Equal(hostproxy.Spec.NodeSelector))
+		Expect(template.Spec.Containers).To(HaveLen(1))
+		container := template.Spec.Containers[0]
+		Expect(container.Name).To(Equal(podtemplate.DefaultContainerName))
+		Expect(container.Image).To(Equal("example.com/proxy:v3"))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "PORTS", Value: "80:10541"}))
+		Expect(container.ReadinessProbe.TCPSocket.Port.IntVal).To(Equal(int32(80)))
+	})
+
+	It("should derive the startup probe from the liveness probe", func() {
+		hostproxy := &networkingv1.Hostproxy{
+			ObjectMeta: metav1.ObjectMeta{Name: "startup-probe", Namespace: "default"},
+			Spec: networkingv1.HostproxySpec{
+				HostPort:    10541,
+				ClusterPort: 80,
+				LivenessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{
+						Exec: &corev1.ExecAction{Command: []string{"true"}},
+					},
+				},
+			},
+		}
+		template, err := podtemplate.ForHostproxy(hostproxy, "example.com/proxy:v3")
+		Expect(err).NotTo(HaveOccurred())
+
+		startupProbe := template.Spec.Containers[0].StartupProbe
+		Expect(startupProbe).NotTo(BeNil())
+		Expect(startupProbe.ProbeHandler).To(Equal(hostproxy.Spec.LivenessProbe.ProbeHandler))
+		Expect(startupProbe.FailureThreshold).To(Equal(int32(30)))
+	})
+})
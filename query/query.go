@@ -0,0 +1,67 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package query summarizes the Hostproxies of a cluster, for instance to feed a dashboard.
+package query
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	networkingv1 "github.com/raw1z/hostproxy/api/v1"
+)
+
+// availableCondition is the type of the condition reported by the controller once the proxy is available
+const availableCondition = "Available"
+
+// PortMappingSummary describes the port mapping of a Hostproxy
+type PortMappingSummary struct {
+	Namespace string
+	Name      string
+	// HostPort is the port of the host which is proxied, including the one allocated by the controller
+	HostPort int32
+	// ClusterPort is the port exposed in the cluster
+	ClusterPort int32
+	// Available is true when the controller reports the proxy as available
+	Available bool
+}
+
+// ListPortMappings returns the port mappings of the Hostproxies of the given namespace,
+// or of all the namespaces when it is empty
+func ListPortMappings(ctx context.Context, c client.Client, namespace string) ([]PortMappingSummary, error) {
+	hostproxies := &networkingv1.HostproxyList{}
+	if err := c.List(ctx, hostproxies, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]PortMappingSummary, 0, len(hostproxies.Items))
+	for _, hostproxy := range hostproxies.Items {
+		hostPort := hostproxy.Spec.HostPort
+		if hostPort == 0 {
+			hostPort = hostproxy.Status.AllocatedHostPort
+		}
+		summaries = append(summaries, PortMappingSummary{
+			Namespace:   hostproxy.Namespace,
+			Name:        hostproxy.Name,
+			HostPort:    hostPort,
+			ClusterPort: hostproxy.Spec.ClusterPort,
+			Available:   meta.IsStatusConditionTrue(hostproxy.Status.Conditions, availableCondition),
+		})
+	}
+	return summaries, nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package query
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkingv1 "github.com/raw1z/hostproxy/api/v1"
+)
+
+var _ = Describe("ListPortMappings", func() {
+	ctx := context.Background()
+
+	It("should summarize the port mappings of the Hostproxies of a namespace", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "query-"}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+		database := &networkingv1.Hostproxy{
+			ObjectMeta: metav1.ObjectMeta{Name: "database", Namespace: namespace.Name},
+			Spec:       networkingv1.HostproxySpec{HostPort: 5432, ClusterPort: 5432},
+		}
+		Expect(k8sClient.Create(ctx, database)).To(Succeed())
+		meta.SetStatusCondition(&database.Status.Conditions, metav1.Condition{Type: availableCondition,
+			Status: metav1.ConditionTrue, Reason: networkingv1.ReasonDeploymentCreated})
+		Expect(k8sClient.Status().Update(ctx, database)).To(Succeed())
+
+		cache := &networkingv1.Hostproxy{
+			ObjectMeta: metav1.ObjectMeta{Name: "cache", Namespace: namespace.Name},
+			Spec:       networkingv1.HostproxySpec{AutoAllocate: true, ClusterPort: 6379},
+		}
+		Expect(k8sClient.Create(ctx, cache)).To(Succeed())
+		cache.Status.AllocatedHostPort = 40000
+		Expect(k8sClient.Status().Update(ctx, cache)).To(Succeed())
+
+		summaries, err := ListPortMappings(ctx, k8sClient, namespace.Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summaries).To(ConsistOf(
+			PortMappingSummary{Namespace: namespace.Name, Name: "database", HostPort: 5432, ClusterPort: 5432, Available: true},
+			PortMappingSummary{Namespace: namespace.Name, Name: "cache", HostPort: 40000, ClusterPort: 6379, Available: false},
+		))
+	})
+})